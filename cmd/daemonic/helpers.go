@@ -1,29 +1,107 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/adamstrickland/daemonic/pkg/daemon"
-	"go.uber.org/zap"
+	"github.com/adamstrickland/daemonic/pkg/logging"
 )
 
-func getLogger(useZap bool) (daemon.Logger, func(), error) {
-	if useZap {
-		zlogger, err := zap.NewProduction()
-		if err != nil {
-			return nil, nil, fmt.Errorf("error creating zap logger: %w", err)
-		}
+// debugTTL is how long SIGUSR1 turns on debug logging for.
+const debugTTL = 5 * time.Minute
 
-		cleanup := func() {
-			zlogger.Sync()
-		}
+// getLogger builds the logger from the configuration, along with the
+// RecentLogs that keep the last entries it logged, for the admin endpoint
+// and for crash reports.
+func getLogger() (daemon.Logger, *daemon.RecentLogs, func(), error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	redactor, err := daemon.NewRedactor(append([]string{daemon.DefaultSecretKeys}, config.LogRedactKeys...)...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	levels := daemon.NewLevelRegistry(level)
+	ctx, cancel := context.WithCancel(context.Background())
+	levels.HandleSignals(ctx, debugTTL)
+
+	backend := logging.BackendSlog
+	if config.UseZap {
+		backend = logging.BackendZap
+	}
+
+	logger, closer, err := logging.New(logging.Config{
+		Backend:   backend,
+		Format:    logging.Format(config.LogFormat),
+		Output:    config.LogOutput,
+		AddSource: config.LogSource,
+		Levels:    levels,
+		Redactor:  redactor,
+		Rotation: logging.Rotation{
+			MaxSize:    int64(config.LogMaxSize) * 1024 * 1024,
+			MaxAge:     config.LogMaxAge,
+			MaxBackups: config.LogMaxBackups,
+			Compress:   config.LogCompress,
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+
+	recentLogs := daemon.NewRecentLogs(config.RecentLogs)
+	logger = recentLogs.Logger(logger, daemon.WithRedactor(redactor))
 
-		return daemon.NewZapAdapter(zlogger), cleanup, nil
-	} else {
-		slogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	stopAdmin := func() {}
+	if config.AdminAddr != "" {
+		stopAdmin = startAdmin(config.AdminAddr, logger, levels, recentLogs)
+	}
+
+	cleanup := func() {
+		stopAdmin()
+		cancel()
+		closer()
+	}
+
+	return logger, recentLogs, cleanup, nil
+}
+
+// archonOptions returns the options every subcommand's Archon shares.
+func archonOptions(logger daemon.Logger, recentLogs *daemon.RecentLogs) []daemon.ArchonOption {
+	return []daemon.ArchonOption{
+		daemon.WithLogger(logger),
+		daemon.WithRecentLogs(recentLogs),
+		daemon.WithCrashReport(os.Stderr),
+	}
+}
+
+// startAdmin serves the diagnostics endpoints on addr, and returns a
+// function that stops serving them.
+func startAdmin(addr string, logger daemon.Logger, levels *daemon.LevelRegistry, recentLogs *daemon.RecentLogs) func() {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/levels", levels)
+	mux.Handle("/debug/logs", recentLogs)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		logger.Info("starting admin server", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("admin server error", "error", fmt.Errorf("admin server: %w", err))
+		}
+	}()
 
-		return daemon.NewSlogAdapter(slogger), func() {}, nil
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
 	}
 }
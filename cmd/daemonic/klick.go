@@ -13,7 +13,7 @@ type Klick struct {
 }
 
 func (c Klick) Run() error {
-	logger, closer, err := getLogger(config.UseZap)
+	logger, recentLogs, closer, err := getLogger()
 	if err != nil {
 		return err
 	}
@@ -21,7 +21,7 @@ func (c Klick) Run() error {
 
 	logger.Info("starting klicker", "config", config)
 
-	archon, err := daemon.NewArchon(daemon.WithLogger(logger))
+	archon, err := daemon.NewArchon(archonOptions(logger, recentLogs)...)
 	if err != nil {
 		return err
 	}
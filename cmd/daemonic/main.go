@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/alecthomas/kong"
 )
@@ -14,7 +15,22 @@ var config struct {
 	Tock  Tock  `cmd:"" help:"Run the Tocker application."`
 
 	// top-level options
-	UseZap bool `name:"zap" optional:"" help:"Use zap logger instead of slog."`
+	UseZap    bool   `name:"zap" optional:"" help:"Use zap logger instead of slog."`
+	LogFormat string `name:"log-format" enum:"json,text,logfmt,console" default:"json" help:"Log format: json, text, logfmt or console (coloured text)."`
+	LogOutput string `name:"log-output" default:"stdout" help:"Log destination: stdout, stderr, syslog, syslog+udp://host:port, syslog+tcp://host:port, syslog+unix:///path, journald, or the path of a file."`
+	LogLevel  string `name:"log-level" enum:"debug,info,warn,error" default:"info" help:"Minimum log level."`
+	LogSource bool   `name:"log-source" optional:"" help:"Include the source location in log entries."`
+
+	LogRedactKeys []string `name:"log-redact-keys" help:"Regular expressions matching further keys whose values are redacted from log entries."`
+
+	AdminAddr  string `name:"admin-addr" help:"Address to serve the admin endpoints (/debug/levels, /debug/logs) on; disabled if empty."`
+	RecentLogs int    `name:"recent-logs" default:"500" help:"Number of recent log entries to keep for diagnostics and crash reports."`
+
+	// log file rotation, when --log-output is a file
+	LogMaxSize    int           `name:"log-max-size" default:"0" help:"Rotate the log file when it reaches this many megabytes (0 to disable)."`
+	LogMaxAge     time.Duration `name:"log-max-age" default:"0" help:"Rotate the log file when it is this old (0 to disable)."`
+	LogMaxBackups int           `name:"log-max-backups" default:"0" help:"Number of rotated log files to keep (0 to keep all)."`
+	LogCompress   bool          `name:"log-compress" optional:"" help:"Gzip rotated log files."`
 }
 
 func main() {
@@ -10,7 +10,7 @@ import (
 type Tick struct{}
 
 func (Tick) Run() error {
-	logger, closer, err := getLogger(config.UseZap)
+	logger, recentLogs, closer, err := getLogger()
 	if err != nil {
 		return err
 	}
@@ -18,7 +18,7 @@ func (Tick) Run() error {
 
 	logger.Info("starting ticker", "config", config)
 
-	archon, err := daemon.NewArchon(daemon.WithLogger(logger))
+	archon, err := daemon.NewArchon(archonOptions(logger, recentLogs)...)
 	if err != nil {
 		return err
 	}
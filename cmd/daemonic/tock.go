@@ -14,7 +14,7 @@ type Tock struct {
 }
 
 func (Tock) Run() error {
-	logger, closer, err := getLogger(config.UseZap)
+	logger, recentLogs, closer, err := getLogger()
 	if err != nil {
 		return err
 	}
@@ -22,7 +22,7 @@ func (Tock) Run() error {
 
 	logger.Info("starting tocker", "config", config)
 
-	archon, err := daemon.NewArchon(daemon.WithLogger(logger))
+	archon, err := daemon.NewArchon(archonOptions(logger, recentLogs)...)
 	if err != nil {
 		return err
 	}
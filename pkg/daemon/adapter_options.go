@@ -0,0 +1,34 @@
+package daemon
+
+// AdapterOption configures SlogAdapter, ZapAdapter and RecentLogger.
+type AdapterOption func(*adapterConfig)
+
+type adapterConfig struct {
+	redactor *Redactor
+	source   bool
+}
+
+// WithRedactor sets the Redactor a logger applies to everything it logs.
+// It defaults to DefaultRedactor; nil turns redaction off.
+func WithRedactor(r *Redactor) AdapterOption {
+	return func(c *adapterConfig) {
+		c.redactor = r
+	}
+}
+
+// WithSource has SlogAdapter look up the caller of each log method, for a
+// handler that records the source location. Finding the caller is costly,
+// so it is off by default; ZapAdapter does it when zap.AddCaller is set.
+func WithSource() AdapterOption {
+	return func(c *adapterConfig) {
+		c.source = true
+	}
+}
+
+func newAdapterConfig(options []AdapterOption) adapterConfig {
+	c := adapterConfig{redactor: DefaultRedactor}
+	for _, opt := range options {
+		opt(&c)
+	}
+	return c
+}
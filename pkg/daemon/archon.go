@@ -2,20 +2,46 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// managed is a daemon added to a running Archon with Add.
+type managed struct {
+	name   string
+	daemon Daemon
+	record *daemonRecord
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
 type Archon struct {
 	logger  Logger
 	timeout time.Duration
 	signals []os.Signal
+	restart RetryPolicy
+	setup   RetryPolicy
+
+	mu      sync.Mutex
+	runCtx  context.Context
+	managed map[string]*managed
+	records []*daemonRecord
+	report  ShutdownReport
+
+	recent      *RecentLogs
+	crashReport io.Writer
 }
 
 type ArchonOption func(*Archon)
@@ -40,11 +66,45 @@ func WithTimeout(d time.Duration) ArchonOption {
 	}
 }
 
+// WithRestartPolicy restarts a daemon whose Run returns a non-permanent
+// error, up to the policy's limit. By default a failing daemon is not
+// restarted.
+func WithRestartPolicy(policy RetryPolicy) ArchonOption {
+	return func(a *Archon) {
+		a.restart = policy
+	}
+}
+
+// WithSetupRetries retries a daemon's Setup on non-permanent errors, up to
+// the policy's limit. By default Setup is attempted once.
+func WithSetupRetries(policy RetryPolicy) ArchonOption {
+	return func(a *Archon) {
+		a.setup = policy
+	}
+}
+
+// WithRecentLogs includes the entries kept by recent in the report of a run
+// that fails.
+func WithRecentLogs(recent *RecentLogs) ArchonOption {
+	return func(a *Archon) {
+		a.recent = recent
+	}
+}
+
+// WithCrashReport writes the report of a run that fails to w, as JSON.
+func WithCrashReport(w io.Writer) ArchonOption {
+	return func(a *Archon) {
+		a.crashReport = w
+	}
+}
+
 func NewArchon(options ...ArchonOption) (*Archon, error) {
 	archon := &Archon{
 		logger:  nil,
 		timeout: 30 * time.Second,
 		signals: []os.Signal{os.Interrupt, syscall.SIGTERM},
+		restart: RetryPolicy{MaxRetries: 0, Delay: 1 * time.Second},
+		setup:   RetryPolicy{MaxRetries: 0, Delay: 1 * time.Second},
 	}
 
 	WithSlog(slog.New(slog.NewJSONHandler(os.Stdout, nil)))(archon)
@@ -56,6 +116,18 @@ func NewArchon(options ...ArchonOption) (*Archon, error) {
 	return archon, nil
 }
 
+// NewPool builds a Pool of replicas that shares the Archon's logger and
+// restart policy. Hand the pool to Run like any other daemon, and keep hold
+// of it to scale it at runtime.
+func (a *Archon) NewPool(name string, factory Factory, options ...PoolOption) (*Pool, error) {
+	defaults := []PoolOption{
+		WithPoolLogger(a.logger),
+		WithReplicaRestartPolicy(a.restart),
+	}
+
+	return NewPool(name, factory, append(defaults, options...)...)
+}
+
 func (a *Archon) Run(ctx context.Context, daemon Daemon) error {
 	// Create root context with cancellation
 	runCtx, cancel := context.WithCancel(ctx)
@@ -66,28 +138,56 @@ func (a *Archon) Run(ctx context.Context, daemon Daemon) error {
 	signal.Notify(sigCh, a.signals...)
 	defer signal.Stop(sigCh)
 
+	main := &managed{name: "main", daemon: daemon, record: newDaemonRecord("main")}
+
+	a.mu.Lock()
+	a.records = []*daemonRecord{main.record}
+	a.report = ShutdownReport{}
+	a.mu.Unlock()
+
 	// Setup the service
-	if err := daemon.Setup(runCtx); err != nil {
-		return fmt.Errorf("service setup failed: %w", err)
+	if err := a.setupDaemon(runCtx, main); err != nil {
+		a.logger.Error("service setup failed", "error", err)
+		return a.finish(0)
 	}
 
 	// Start service in goroutine
 	errCh := make(chan error, 1)
-	go func() {
-		a.logger.Info("starting service")
-		if err := daemon.Run(runCtx); err != nil {
-			errCh <- err
+	a.start(runCtx, main, func(err error) {
+		select {
+		case errCh <- err:
+		default:
 		}
+	})
+
+	// Accept daemons added at runtime from here on
+	a.mu.Lock()
+	a.runCtx = runCtx
+	a.managed = make(map[string]*managed)
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.runCtx = nil
+		a.mu.Unlock()
 	}()
 
-	// Wait for shutdown signal or error
+	// Wait for shutdown signal or error; the error itself is kept in the
+	// failing daemon's report
 	select {
 	case err := <-errCh:
-		return fmt.Errorf("service error: %w", err)
+		a.logger.Error("service failed, shutting down", "error", err)
 	case sig := <-sigCh:
 		a.logger.Info("received signal", "signal", sig)
 	}
 
+	// Stop accepting new daemons, and take the added ones down with us
+	a.mu.Lock()
+	a.runCtx = nil
+	added := a.managed
+	a.managed = nil
+	a.mu.Unlock()
+
 	// Graceful shutdown
 	cancel() // Signal context cancellation to service
 
@@ -95,11 +195,326 @@ func (a *Archon) Run(ctx context.Context, daemon Daemon) error {
 	defer shutdownCancel()
 
 	a.logger.Info("shutting down gracefully", "timeout", a.timeout)
-	if err := daemon.Shutdown(shutdownCtx); err != nil {
-		return fmt.Errorf("shutdown error: %w", err)
+	began := time.Now()
+
+	var wg sync.WaitGroup
+	for _, m := range added {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = a.stop(shutdownCtx, m)
+		}()
+	}
+	wg.Wait()
+	_ = a.stop(shutdownCtx, main)
+
+	return a.finish(time.Since(began))
+}
+
+// Report returns the report of the last completed run, or of the run in
+// progress so far.
+func (a *Archon) Report() ShutdownReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.report.Daemons != nil {
+		return a.report
+	}
+
+	return a.buildReport(0)
+}
+
+// finish builds, keeps and logs the report of the run, and returns its
+// joined error.
+func (a *Archon) finish(shutdown time.Duration) error {
+	a.mu.Lock()
+	report := a.buildReport(shutdown)
+	a.report = report
+	a.mu.Unlock()
+
+	for _, d := range report.Daemons {
+		args := []any{"daemon", d.Name, "setup", d.Setup, "uptime", d.Uptime, "shutdown", d.Shutdown, "restarts", d.Restarts}
+		if d.Err != nil {
+			a.logger.Error("daemon report", append(args, "error", d.Err)...)
+		} else {
+			a.logger.Info("daemon report", args...)
+		}
+	}
+
+	if report.Err != nil {
+		a.logger.Error("shutdown complete", "duration", report.Shutdown, "error", report.Err)
+		a.writeCrashReport(report)
+	} else {
+		a.logger.Info("shutdown complete", "duration", report.Shutdown)
+	}
+
+	return report.Err
+}
+
+// buildReport must be called with a.mu held.
+func (a *Archon) buildReport(shutdown time.Duration) ShutdownReport {
+	report := ShutdownReport{
+		Daemons:  make([]DaemonReport, 0, len(a.records)),
+		Shutdown: shutdown,
+	}
+
+	var errs []error
+	for _, r := range a.records {
+		d := r.snapshot()
+		report.Daemons = append(report.Daemons, d)
+		if d.Err != nil {
+			errs = append(errs, fmt.Errorf("daemon %q: %w", d.Name, d.Err))
+		}
+	}
+	report.Err = errors.Join(errs...)
+
+	if report.Err != nil && a.recent != nil {
+		report.RecentLogs = a.recent.Entries(RecentFilter{MinLevel: slog.LevelDebug})
+	}
+
+	return report
+}
+
+func (a *Archon) writeCrashReport(report ShutdownReport) {
+	if a.crashReport == nil {
+		return
+	}
+
+	enc := json.NewEncoder(a.crashReport)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		a.logger.Error("writing crash report", "error", err)
+	}
+}
+
+// Add registers daemon with a running Archon under name. The daemon is set
+// up (honouring the setup retry policy) and then run alongside the others
+// under the restart policy. It is shut down along with everything else, or
+// earlier by Remove. If it gives up, it is removed and shut down on its
+// own; the other daemons carry on.
+func (a *Archon) Add(ctx context.Context, name string, daemon Daemon) error {
+	a.mu.Lock()
+	if a.runCtx == nil {
+		a.mu.Unlock()
+		return fmt.Errorf("cannot add daemon %q: archon is not running", name)
+	}
+	if _, exists := a.managed[name]; exists {
+		a.mu.Unlock()
+		return fmt.Errorf("cannot add daemon %q: already registered", name)
+	}
+
+	// Reserve the name while the daemon is set up
+	m := &managed{name: name, daemon: daemon, record: newDaemonRecord(name)}
+	a.managed[name] = m
+	a.records = append(a.records, m.record)
+	a.mu.Unlock()
+
+	a.logger.Info("adding daemon", "daemon", name)
+	if err := a.setupDaemon(ContextWithIdentity(ctx, Identity{Name: name}), m); err != nil {
+		err = fmt.Errorf("daemon %q setup failed: %w", name, err)
+		return errors.Join(err, a.abandon(ctx, m))
+	}
+
+	a.mu.Lock()
+	// Run may have begun shutting down while the daemon was being set up
+	if a.runCtx == nil || a.managed[name] != m {
+		a.mu.Unlock()
+		err := fmt.Errorf("cannot add daemon %q: archon is shutting down", name)
+		return errors.Join(err, a.abandon(ctx, m))
 	}
 
-	a.logger.Info("shutdown complete")
+	a.start(ContextWithIdentity(a.runCtx, Identity{Name: name}), m, func(err error) {
+		a.failed(m, err)
+	})
+	a.mu.Unlock()
 
 	return nil
 }
+
+// abandon unregisters m, which never started running, and shuts it down to
+// release whatever its Setup acquired.
+func (a *Archon) abandon(ctx context.Context, m *managed) error {
+	a.mu.Lock()
+	if a.managed[m.name] == m {
+		delete(a.managed, m.name)
+	}
+	a.records = slices.DeleteFunc(a.records, func(r *daemonRecord) bool {
+		return r == m.record
+	})
+	a.mu.Unlock()
+
+	if err := m.daemon.Shutdown(ContextWithIdentity(ctx, Identity{Name: m.name})); err != nil {
+		return fmt.Errorf("daemon %q shutdown failed: %w", m.name, err)
+	}
+	return nil
+}
+
+// failed unregisters m, a daemon added at runtime that has given up, and
+// shuts it down, leaving the other daemons running. If Run or Remove is
+// already stopping m, they shut it down instead.
+func (a *Archon) failed(m *managed, err error) {
+	a.logger.Error("added daemon failed, removing it", "daemon", m.name, "error", err)
+
+	a.mu.Lock()
+	owned := a.managed[m.name] == m
+	if owned {
+		delete(a.managed, m.name)
+	}
+	a.mu.Unlock()
+	if !owned {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	began := time.Now()
+	shutdownErr := m.daemon.Shutdown(ContextWithIdentity(ctx, Identity{Name: m.name}))
+	if shutdownErr != nil {
+		shutdownErr = fmt.Errorf("shutdown failed: %w", shutdownErr)
+		a.logger.Error("shutting down failed daemon", "daemon", m.name, "error", shutdownErr)
+	}
+	m.record.shutdownDone(time.Since(began), shutdownErr)
+}
+
+// Remove stops the daemon registered under name, and shuts it down. The
+// other daemons are left running.
+func (a *Archon) Remove(ctx context.Context, name string) error {
+	a.mu.Lock()
+	m, exists := a.managed[name]
+	if exists && m.done != nil {
+		delete(a.managed, name)
+	}
+	a.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("cannot remove daemon %q: not registered", name)
+	}
+	if m.done == nil {
+		return fmt.Errorf("cannot remove daemon %q: still setting up", name)
+	}
+
+	a.logger.Info("removing daemon", "daemon", name)
+	return a.stop(ctx, m)
+}
+
+// Daemons returns the names of the daemons added at runtime.
+func (a *Archon) Daemons() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	names := make([]string, 0, len(a.managed))
+	for name := range a.managed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// start runs m in the background, passing the error that made it give up
+// to onFail.
+func (a *Archon) start(ctx context.Context, m *managed, onFail func(error)) {
+	runCtx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		err := a.runDaemon(runCtx, m)
+		m.record.stop(err)
+		close(m.done)
+		if err != nil {
+			onFail(err)
+		}
+	}()
+}
+
+func (a *Archon) stop(ctx context.Context, m *managed) error {
+	if m.cancel == nil {
+		return nil
+	}
+
+	began := time.Now()
+	m.cancel()
+
+	// Shut the daemon down whether or not Run has returned in time, so that
+	// it still gets the chance to release what it holds.
+	var stopErr error
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		stopErr = fmt.Errorf("did not stop: %w", ctx.Err())
+	}
+
+	err := m.daemon.Shutdown(ContextWithIdentity(ctx, Identity{Name: m.name}))
+	if err != nil {
+		err = fmt.Errorf("shutdown failed: %w", err)
+	}
+	err = errors.Join(stopErr, err)
+	m.record.shutdownDone(time.Since(began), err)
+	if err != nil {
+		return fmt.Errorf("daemon %q %w", m.name, err)
+	}
+
+	return nil
+}
+
+func (a *Archon) setupDaemon(ctx context.Context, m *managed) error {
+	began := time.Now()
+	var delay time.Duration
+	for retries := 0; ; retries++ {
+		err := m.daemon.Setup(ctx)
+		if err == nil {
+			m.record.setupDone(time.Since(began), nil)
+			return nil
+		}
+
+		if !a.setup.Retryable(err, retries) {
+			m.record.setupDone(time.Since(began), err)
+			return err
+		}
+
+		delay = a.setup.Wait(err, retries, delay)
+		a.logger.Warn("service setup failed, retrying", "daemon", m.name, "error", err, "class", Classify(err), "retry", retries+1, "delay", delay)
+		if !Sleep(ctx, delay) {
+			m.record.setupDone(time.Since(began), err)
+			return err
+		}
+	}
+}
+
+// runDaemon runs m until ctx is done, restarting it according to the
+// restart policy. It returns the error that made it give up, if any.
+func (a *Archon) runDaemon(ctx context.Context, m *managed) error {
+	m.record.start()
+	var delay time.Duration
+	for restarts := 0; ; restarts++ {
+		a.logger.Info("starting service", "daemon", m.name, "restarts", restarts)
+		err := runRecovering(ctx, m.daemon)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		if !a.restart.Retryable(err, restarts) {
+			return err
+		}
+
+		delay = a.restart.Wait(err, restarts, delay)
+		a.logger.Warn("service failed, restarting", "daemon", m.name, "error", err, "class", Classify(err), "restart", restarts+1, "delay", delay)
+		m.record.restart()
+		if !Sleep(ctx, delay) {
+			return nil
+		}
+	}
+}
+
+// runRecovering runs daemon, turning a panic into a permanent error.
+func runRecovering(ctx context.Context, daemon Daemon) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v\n%s", r, debug.Stack()))
+		}
+	}()
+
+	return daemon.Run(ctx)
+}
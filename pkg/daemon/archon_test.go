@@ -0,0 +1,274 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+)
+
+// failingDaemon fails Setup with setupErr, and each Run with the next of
+// runErrs, the last of them over and over.
+type failingDaemon struct {
+	setupErr    error
+	shutdownErr error
+
+	mu      sync.Mutex
+	runErrs []error
+	runs    int
+}
+
+func (d *failingDaemon) Setup(context.Context) error { return d.setupErr }
+
+func (d *failingDaemon) Run(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.runErrs[min(d.runs, len(d.runErrs)-1)]
+	d.runs++
+	return err
+}
+
+func (d *failingDaemon) Shutdown(context.Context) error { return d.shutdownErr }
+
+func TestArchonReport(t *testing.T) {
+	transient := daemon.Transient(errors.New("flaky"))
+	permanent := daemon.Permanent(errors.New("broken"))
+
+	tests := []struct {
+		name     string
+		daemon   *failingDaemon
+		runs     int
+		restarts int
+		errs     []string
+	}{
+		{"restarts used up", &failingDaemon{runErrs: []error{transient}}, 3, 2, []string{"flaky"}},
+		{"permanent", &failingDaemon{runErrs: []error{permanent}}, 1, 0, []string{"broken"}},
+		{"permanent after restarts", &failingDaemon{runErrs: []error{transient, transient, permanent}}, 3, 2, []string{"broken"}},
+		{"setup", &failingDaemon{setupErr: permanent}, 0, 0, []string{"broken"}},
+		{"shutdown", &failingDaemon{runErrs: []error{permanent}, shutdownErr: errors.New("stuck")}, 1, 0, []string{"broken", "shutdown failed: stuck"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := daemontest.NewLogger(t)
+			archon, err := daemon.NewArchon(
+				daemon.WithLogger(logger),
+				daemon.WithRestartPolicy(daemon.RetryPolicy{MaxRetries: 2, Delay: time.Millisecond}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = archon.Run(context.Background(), tt.daemon)
+			if err == nil {
+				t.Fatal("got no error from a failed run")
+			}
+			if tt.daemon.runs != tt.runs {
+				t.Errorf("got %d runs, want %d", tt.daemon.runs, tt.runs)
+			}
+
+			report := archon.Report()
+			if len(report.Daemons) != 1 {
+				t.Fatalf("got %d daemon reports, want 1", len(report.Daemons))
+			}
+			d := report.Daemons[0]
+			if d.Name != "main" || d.Restarts != tt.restarts {
+				t.Errorf("got %s with %d restarts, want main with %d", d.Name, d.Restarts, tt.restarts)
+			}
+			for _, want := range tt.errs {
+				if d.Err == nil || !strings.Contains(d.Err.Error(), want) {
+					t.Errorf("got daemon error %v, want it to include %q", d.Err, want)
+				}
+			}
+			if want := `daemon "main": ` + d.Err.Error(); report.Err.Error() != want {
+				t.Errorf("got report error %q, want %q", report.Err, want)
+			}
+
+			logger.AssertLogged(slog.LevelError, "daemon report", "daemon", "main", "restarts", tt.restarts)
+			logger.AssertLogged(slog.LevelError, "shutdown complete")
+			if got := len(logger.Find(slog.LevelWarn, "service failed, restarting")); got != tt.restarts {
+				t.Errorf("got %d restarts logged, want %d", got, tt.restarts)
+			}
+		})
+	}
+}
+
+func TestArchonCrashReport(t *testing.T) {
+	recent := daemon.NewRecentLogs(100)
+	logger := daemontest.NewLogger(t)
+	var crash bytes.Buffer
+	archon, err := daemon.NewArchon(
+		daemon.WithLogger(recent.Logger(logger)),
+		daemon.WithRecentLogs(recent),
+		daemon.WithCrashReport(&crash),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archon.Run(context.Background(), &failingDaemon{runErrs: []error{daemon.Permanent(errors.New("broken"))}})
+
+	if len(archon.Report().RecentLogs) == 0 {
+		t.Error("got no recent logs in the report")
+	}
+
+	var written struct {
+		Daemons []struct {
+			Name  string `json:"name"`
+			Error string `json:"error"`
+		} `json:"daemons"`
+		Error      string            `json:"error"`
+		RecentLogs []daemon.LogEntry `json:"recent_logs"`
+	}
+	if err := json.Unmarshal(crash.Bytes(), &written); err != nil {
+		t.Fatalf("crash report is not JSON: %v\n%s", err, crash.String())
+	}
+	if len(written.Daemons) != 1 || written.Daemons[0].Name != "main" || written.Daemons[0].Error != "broken" {
+		t.Errorf("got daemons %+v in the crash report", written.Daemons)
+	}
+	if written.Error != `daemon "main": broken` {
+		t.Errorf("got error %q in the crash report", written.Error)
+	}
+	found := false
+	for _, e := range written.RecentLogs {
+		found = found || e.Message == "service failed, shutting down"
+	}
+	if !found {
+		t.Errorf("got recent logs %+v, want the failure among them", written.RecentLogs)
+	}
+}
+
+// stubDaemon runs until stopped, unless Setup or Run fail, and counts its
+// shutdowns.
+type stubDaemon struct {
+	setupErr error
+	runErr   error
+	// exit, if set, makes Run fail with runErr once it is closed.
+	exit chan struct{}
+
+	shutdowns atomic.Int32
+}
+
+func (d *stubDaemon) Setup(context.Context) error { return d.setupErr }
+
+func (d *stubDaemon) Run(ctx context.Context) error {
+	if d.exit != nil {
+		select {
+		case <-d.exit:
+			return d.runErr
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if d.runErr != nil {
+		return d.runErr
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (d *stubDaemon) Shutdown(context.Context) error {
+	d.shutdowns.Add(1)
+	return nil
+}
+
+func TestArchonAddRemove(t *testing.T) {
+	ctx := context.Background()
+	logger := daemontest.NewLogger(t)
+	archon, err := daemon.NewArchon(daemon.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archon.Add(ctx, "early", &stubDaemon{}); err == nil {
+		t.Error("added a daemon before the archon was running")
+	}
+
+	main := &stubDaemon{exit: make(chan struct{}), runErr: daemon.Permanent(errors.New("done"))}
+	ran := make(chan error, 1)
+	go func() { ran <- archon.Run(ctx, main) }()
+
+	a := &stubDaemon{}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		err := archon.Add(ctx, "a", a)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("adding a daemon to a running archon: %v", err)
+		}
+	}
+
+	if err := archon.Add(ctx, "a", &stubDaemon{}); err == nil {
+		t.Error("added a second daemon under the same name")
+	}
+
+	broken := &stubDaemon{setupErr: daemon.Permanent(errors.New("no setup"))}
+	if err := archon.Add(ctx, "broken", broken); err == nil {
+		t.Error("got no error adding a daemon whose setup fails")
+	}
+	if n := broken.shutdowns.Load(); n != 1 {
+		t.Errorf("daemon whose setup failed was shut down %d times, want 1", n)
+	}
+
+	failing := &stubDaemon{runErr: daemon.Permanent(errors.New("failed"))}
+	if err := archon.Add(ctx, "failing", failing); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitFor(slog.LevelError, "added daemon failed, removing it", time.Second, "daemon", "failing")
+	// It is removed and shut down just after.
+	for deadline := time.Now().Add(time.Second); failing.shutdowns.Load() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("failed daemon was not shut down")
+		}
+	}
+
+	if got, want := archon.Daemons(), []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("got daemons %q, want %q", got, want)
+	}
+	if err := archon.Remove(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := archon.Remove(ctx, "a"); err == nil {
+		t.Error("removed a daemon twice")
+	}
+
+	b := &stubDaemon{}
+	if err := archon.Add(ctx, "b", b); err != nil {
+		t.Fatal(err)
+	}
+	close(main.exit)
+	if err := <-ran; err == nil {
+		t.Error("got no error from the failed run")
+	}
+
+	for name, d := range map[string]*stubDaemon{"main": main, "a": a, "failing": failing, "b": b} {
+		if n := d.shutdowns.Load(); n != 1 {
+			t.Errorf("daemon %q was shut down %d times, want 1", name, n)
+		}
+	}
+	if err := archon.Add(ctx, "late", &stubDaemon{}); err == nil {
+		t.Error("added a daemon after the archon stopped")
+	}
+
+	var names []string
+	for _, d := range archon.Report().Daemons {
+		names = append(names, d.Name)
+		if wantErr := d.Name == "main" || d.Name == "failing"; (d.Err != nil) != wantErr {
+			t.Errorf("daemon %q: got error %v", d.Name, d.Err)
+		}
+	}
+	if want := []string{"main", "a", "failing", "b"}; !slices.Equal(names, want) {
+		t.Errorf("got reports of %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"runtime"
+	"strings"
+)
+
+const pkgPath = "github.com/adamstrickland/daemonic/pkg/daemon."
+
+// loggingFrames are skipped when looking for the caller of a log method, so
+// that the source location points at the code doing the logging rather than
+// at the adapters and wrappers in between.
+var loggingFrames = []string{
+	pkgPath + "(*SlogAdapter)",
+	pkgPath + "(*ZapAdapter)",
+	pkgPath + "(*SlogHandler)",
+	pkgPath + "(*LogrSink)",
+	pkgPath + "(*LeveledLogger)",
+	pkgPath + "(*SampledLogger)",
+	pkgPath + "(*RecentLogger)",
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest.(*Logger)",
+	"github.com/adamstrickland/daemonic/pkg/logging/kafkalog.(*KgoLogger)",
+	"log/slog.",
+	"github.com/go-logr/logr.",
+}
+
+// callerPC returns the program counter of the first caller that is not
+// part of the logging machinery, or zero if there is none.
+func callerPC() uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+
+	for _, pc := range pcs[:n] {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if !isLoggingFrame(frame.Function) {
+			return pc
+		}
+	}
+
+	return 0
+}
+
+func isLoggingFrame(function string) bool {
+	for _, prefix := range loggingFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/logging/kafkalog"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// here returns the location of the line after the one calling it.
+func here() string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line+1)
+}
+
+// callers log through the wrappers whose frames are skipped, returning
+// where they logged from.
+var callers = []struct {
+	name string
+	log  func(logger daemon.Logger) string
+}{
+	{"adapter", func(logger daemon.Logger) string {
+		at := here()
+		logger.Info("hi")
+		return at
+	}},
+	{"context", func(logger daemon.Logger) string {
+		at := here()
+		logger.InfoContext(context.Background(), "hi")
+		return at
+	}},
+	{"child", func(logger daemon.Logger) string {
+		at := here()
+		logger.Named("child").With("k", "v").Info("hi")
+		return at
+	}},
+	{"leveled", func(logger daemon.Logger) string {
+		leveled := daemon.NewLevelRegistry(slog.LevelDebug).Logger(logger)
+		at := here()
+		leveled.Info("hi")
+		return at
+	}},
+	{"sampled", func(logger daemon.Logger) string {
+		sampled, _ := daemon.NewSampledLogger(logger, daemon.Sampling{Interval: time.Second, First: 1})
+		at := here()
+		sampled.Info("hi")
+		return at
+	}},
+	{"recent", func(logger daemon.Logger) string {
+		recent := daemon.NewRecentLogs(1).Logger(logger)
+		at := here()
+		recent.Info("hi")
+		return at
+	}},
+	{"slog", func(logger daemon.Logger) string {
+		slogger := slog.New(daemon.NewSlogHandler(logger, nil))
+		at := here()
+		slogger.Info("hi")
+		return at
+	}},
+	{"logr", func(logger daemon.Logger) string {
+		logr := daemon.NewLogr(logger, 0)
+		at := here()
+		logr.Info("hi")
+		return at
+	}},
+	{"kgo", func(logger daemon.Logger) string {
+		kgoLogger := kafkalog.NewKgoLogger(logger, kgo.LogLevelInfo)
+		at := here()
+		kgoLogger.Log(kgo.LogLevelInfo, "hi")
+		return at
+	}},
+}
+
+func TestSlogAdapterSource(t *testing.T) {
+	for _, tt := range callers {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := daemon.NewSlogAdapter(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})), daemon.WithSource())
+
+			want := tt.log(logger)
+
+			var entry struct {
+				Source struct {
+					File string `json:"file"`
+					Line int    `json:"line"`
+				} `json:"source"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("%v: %s", err, buf.String())
+			}
+			if got := fmt.Sprintf("%s:%d", filepath.Base(entry.Source.File), entry.Source.Line); got != want {
+				t.Errorf("got source %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSlogAdapterWithoutSource(t *testing.T) {
+	var buf bytes.Buffer
+	logger := daemon.NewSlogAdapter(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})))
+
+	logger.Info("hi")
+
+	if strings.Contains(buf.String(), "caller_test.go") {
+		t.Errorf("got a source location without WithSource: %s", buf.String())
+	}
+}
+
+func TestZapAdapterCaller(t *testing.T) {
+	for _, tt := range callers {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zap.DebugLevel)
+			logger := daemon.NewZapAdapter(zap.New(core, zap.AddCaller()))
+
+			want := tt.log(logger)
+
+			var entry struct {
+				Caller string `json:"caller"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("%v: %s", err, buf.String())
+			}
+			if got := filepath.Base(entry.Caller); got != want {
+				t.Errorf("got caller %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import "context"
+
+// Keys under which the adapters record the values carried by a context.
+const (
+	CorrelationIDKey = "correlation_id"
+	TraceIDKey       = "trace_id"
+	SpanIDKey        = "span_id"
+	DaemonKey        = "daemon"
+	ReplicaKey       = "replica"
+)
+
+type (
+	identityKey      struct{}
+	correlationIDKey struct{}
+	traceKey         struct{}
+)
+
+// Trace identifies the trace and span a piece of work belongs to.
+type Trace struct {
+	TraceID string
+	SpanID  string
+}
+
+// ContextWithIdentity returns a copy of ctx carrying id.
+func ContextWithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the daemon ctx was handed to.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// ContextWithCorrelationID returns a copy of ctx carrying the correlation
+// ID of the request or message being processed.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ContextWithTrace returns a copy of ctx carrying trace.
+func ContextWithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	trace, ok := ctx.Value(traceKey{}).(Trace)
+	return trace, ok && trace.TraceID != ""
+}
+
+// ContextArgs returns the key/value pairs the adapters add to entries
+// logged with ctx, for Logger implementations that want to do the same.
+func ContextArgs(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+
+	var args []any
+	if id, ok := IdentityFromContext(ctx); ok {
+		args = append(args, DaemonKey, id.Name)
+		if id.Pooled {
+			args = append(args, ReplicaKey, id.Index)
+		}
+	}
+	if id, ok := CorrelationIDFromContext(ctx); ok {
+		args = append(args, CorrelationIDKey, id)
+	}
+	if trace, ok := TraceFromContext(ctx); ok {
+		args = append(args, TraceIDKey, trace.TraceID)
+		if trace.SpanID != "" {
+			args = append(args, SpanIDKey, trace.SpanID)
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,299 @@
+// Package daemontest provides helpers for testing code that logs through a
+// daemon.Logger.
+package daemontest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+)
+
+// Entry is a captured log entry.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Logger  string
+	Message string
+	Fields  map[string]any
+}
+
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", e.Level, e.Message)
+	if e.Logger != "" {
+		fmt.Fprintf(&b, " logger=%s", e.Logger)
+	}
+	for _, key := range slices.Sorted(maps.Keys(e.Fields)) {
+		fmt.Fprintf(&b, " %s=%v", key, e.Fields[key])
+	}
+	return b.String()
+}
+
+// matches reports whether e has level and msg, and has each of the
+// key/value pairs in args among its fields. Values are compared by how
+// they print, so that an int matches the int64 slog stores it as.
+func (e Entry) matches(level slog.Level, msg string, args []any) bool {
+	if e.Level != level || e.Message != msg {
+		return false
+	}
+
+	want := fields(nil, nil, args)
+	for key, value := range want {
+		got, ok := e.Fields[key]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// recorder holds the entries captured by a Logger and all its children.
+type recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+	// added is closed, and replaced, whenever an entry is captured.
+	added chan struct{}
+	// resets counts the calls to Reset.
+	resets int
+}
+
+// Logger is a daemon.Logger that captures what is logged through it, for
+// assertions in tests. It logs at every level. Child loggers created with
+// With and Named capture into their parent.
+type Logger struct {
+	t    testing.TB
+	rec  *recorder
+	name string
+	args []any
+}
+
+var _ daemon.Logger = (*Logger)(nil)
+
+// NewLogger returns a Logger whose entries are dumped to t's log if the
+// test fails.
+func NewLogger(t testing.TB) *Logger {
+	t.Helper()
+
+	l := &Logger{t: t, rec: &recorder{added: make(chan struct{})}}
+	t.Cleanup(func() {
+		if t.Failed() {
+			l.Dump()
+		}
+	})
+	return l
+}
+
+func (l *Logger) Debug(msg string, args ...any) {
+	l.capture(nil, slog.LevelDebug, msg, args)
+}
+
+func (l *Logger) Info(msg string, args ...any) {
+	l.capture(nil, slog.LevelInfo, msg, args)
+}
+
+func (l *Logger) Warn(msg string, args ...any) {
+	l.capture(nil, slog.LevelWarn, msg, args)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.capture(nil, slog.LevelError, msg, args)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.capture(ctx, slog.LevelDebug, msg, args)
+}
+
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.capture(ctx, slog.LevelInfo, msg, args)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.capture(ctx, slog.LevelWarn, msg, args)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.capture(ctx, slog.LevelError, msg, args)
+}
+
+func (l *Logger) With(args ...any) daemon.Logger {
+	l2 := *l
+	l2.args = append(append([]any(nil), l.args...), args...)
+	return &l2
+}
+
+func (l *Logger) Named(name string) daemon.Logger {
+	l2 := *l
+	if l.name == "" {
+		l2.name = name
+	} else if name != "" {
+		l2.name = l.name + "." + name
+	}
+	return &l2
+}
+
+func (l *Logger) capture(ctx context.Context, level slog.Level, msg string, args []any) {
+	var ctxArgs []any
+	if ctx != nil {
+		ctxArgs = daemon.ContextArgs(ctx)
+	}
+
+	e := Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Logger:  l.name,
+		Message: msg,
+		Fields:  fields(ctxArgs, l.args, args),
+	}
+
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	l.rec.entries = append(l.rec.entries, e)
+	close(l.rec.added)
+	l.rec.added = make(chan struct{})
+}
+
+// Entries returns everything captured so far, oldest first.
+func (l *Logger) Entries() []Entry {
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	return append([]Entry(nil), l.rec.entries...)
+}
+
+// Find returns the captured entries with level and msg whose fields
+// include the key/value pairs in args.
+func (l *Logger) Find(level slog.Level, msg string, args ...any) []Entry {
+	var found []Entry
+	for _, e := range l.Entries() {
+		if e.matches(level, msg, args) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Reset discards everything captured so far.
+func (l *Logger) Reset() {
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	l.rec.entries = nil
+	l.rec.resets++
+}
+
+// AssertLogged fails the test unless an entry with level and msg, and the
+// key/value pairs in args, has been captured. It returns the first such
+// entry.
+func (l *Logger) AssertLogged(level slog.Level, msg string, args ...any) Entry {
+	l.t.Helper()
+
+	found := l.Find(level, msg, args...)
+	if len(found) == 0 {
+		l.t.Errorf("expected %s %q %v to have been logged", level, msg, args)
+		return Entry{}
+	}
+	return found[0]
+}
+
+// AssertNotLogged fails the test if an entry with msg has been captured,
+// at any level.
+func (l *Logger) AssertNotLogged(msg string) {
+	l.t.Helper()
+
+	for _, e := range l.Entries() {
+		if e.Message == msg {
+			l.t.Errorf("expected %q not to have been logged, got %s", msg, e)
+			return
+		}
+	}
+}
+
+// WaitFor waits up to timeout for an entry with level and msg, and the
+// key/value pairs in args, to be captured, failing the test if none is. It
+// returns the first such entry, which may have been captured before
+// WaitFor was called.
+func (l *Logger) WaitFor(level slog.Level, msg string, timeout time.Duration, args ...any) Entry {
+	l.t.Helper()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	seen, resets := 0, 0
+	for {
+		l.rec.mu.Lock()
+		if resets != l.rec.resets {
+			// Reset was called while waiting.
+			seen, resets = 0, l.rec.resets
+		}
+		entries := l.rec.entries[seen:]
+		added := l.rec.added
+		l.rec.mu.Unlock()
+
+		for _, e := range entries {
+			if e.matches(level, msg, args) {
+				return e
+			}
+		}
+		seen += len(entries)
+
+		select {
+		case <-added:
+		case <-deadline.C:
+			l.t.Fatalf("timed out after %s waiting for %s %q %v to be logged", timeout, level, msg, args)
+			return Entry{}
+		}
+	}
+}
+
+// Dump writes every captured entry to the test log.
+func (l *Logger) Dump() {
+	l.t.Helper()
+
+	entries := l.Entries()
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	l.t.Logf("%d captured log entries:\n%s", len(entries), strings.Join(lines, "\n"))
+}
+
+// fields pairs up the args in lists the way slog does.
+func fields(lists ...[]any) map[string]any {
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	for _, args := range lists {
+		record.Add(args...)
+	}
+	if record.NumAttrs() == 0 {
+		return nil
+	}
+
+	m := make(map[string]any, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		m[a.Key] = value(a.Value)
+		return true
+	})
+	return m
+}
+
+// value unwraps v, turning groups into maps.
+func value(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+
+	m := make(map[string]any, len(v.Group()))
+	for _, a := range v.Group() {
+		m[a.Key] = value(a.Value)
+	}
+	return m
+}
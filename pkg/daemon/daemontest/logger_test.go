@@ -0,0 +1,177 @@
+package daemontest_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+)
+
+// recordingT is a testing.TB that records failures instead of failing the
+// test running it.
+type recordingT struct {
+	testing.TB
+	failures []string
+	logs     []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Cleanup(func()) {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (r *recordingT) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+// run calls f with a Logger on a recordingT, in a goroutine of its own so
+// that Fatalf can end it.
+func run(t *testing.T, f func(l *daemontest.Logger)) *recordingT {
+	r := &recordingT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(daemontest.NewLogger(r))
+	}()
+	<-done
+	return r
+}
+
+func TestLoggerCapturesChildren(t *testing.T) {
+	l := daemontest.NewLogger(t)
+	ctx := daemon.ContextWithIdentity(context.Background(), daemon.Identity{Name: "ticker"})
+
+	l.Named("a").With("k", 1).Named("b").InfoContext(ctx, "hello", "n", 2)
+
+	entries := l.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Logger != "a.b" || e.Level != slog.LevelInfo || e.Message != "hello" {
+		t.Errorf("got %s, want INFO hello from a.b", e)
+	}
+	want := map[string]any{"k": int64(1), "n": int64(2), daemon.DaemonKey: "ticker"}
+	for key, value := range want {
+		if e.Fields[key] != value {
+			t.Errorf("got %s %v, want %v", key, e.Fields[key], value)
+		}
+	}
+}
+
+func TestLoggerFind(t *testing.T) {
+	l := daemontest.NewLogger(t)
+	l.Info("tick", "n", 1)
+	l.Info("tick", "n", 2, "group", slog.GroupValue(slog.String("x", "y")))
+	l.Warn("tick", "n", 2)
+
+	tests := []struct {
+		name  string
+		level slog.Level
+		args  []any
+		want  int
+	}{
+		{"message and level", slog.LevelInfo, nil, 2},
+		{"int field", slog.LevelInfo, []any{"n", 2}, 1},
+		{"other level", slog.LevelWarn, []any{"n", 2}, 1},
+		{"group field", slog.LevelInfo, []any{"group", map[string]any{"x": "y"}}, 1},
+		{"no match", slog.LevelInfo, []any{"n", 3}, 0},
+		{"missing field", slog.LevelWarn, []any{"m", 2}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(l.Find(tt.level, "tick", tt.args...)); got != tt.want {
+				t.Errorf("got %d entries, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerAssertions(t *testing.T) {
+	tests := []struct {
+		name     string
+		assert   func(l *daemontest.Logger)
+		failures int
+	}{
+		{"logged", func(l *daemontest.Logger) {
+			l.Info("tick", "n", 1)
+			l.AssertLogged(slog.LevelInfo, "tick", "n", 1)
+		}, 0},
+		{"not logged", func(l *daemontest.Logger) {
+			l.AssertLogged(slog.LevelInfo, "tick")
+		}, 1},
+		{"logged at another level", func(l *daemontest.Logger) {
+			l.Warn("tick")
+			l.AssertLogged(slog.LevelInfo, "tick")
+		}, 1},
+		{"absent", func(l *daemontest.Logger) {
+			l.Info("tock")
+			l.AssertNotLogged("tick")
+		}, 0},
+		{"present", func(l *daemontest.Logger) {
+			l.Debug("tick")
+			l.AssertNotLogged("tick")
+		}, 1},
+		{"reset", func(l *daemontest.Logger) {
+			l.Info("tick")
+			l.Reset()
+			l.AssertNotLogged("tick")
+		}, 0},
+		{"wait times out", func(l *daemontest.Logger) {
+			l.WaitFor(slog.LevelInfo, "tick", 10*time.Millisecond)
+			l.Info("not reached")
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := run(t, tt.assert)
+			if len(r.failures) != tt.failures {
+				t.Errorf("got failures %q, want %d", r.failures, tt.failures)
+			}
+		})
+	}
+}
+
+func TestLoggerWaitFor(t *testing.T) {
+	l := daemontest.NewLogger(t)
+	l.Info("tick", "n", 1)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Reset()
+		l.Info("tick", "n", 2)
+	}()
+
+	if e := l.WaitFor(slog.LevelInfo, "tick", time.Second, "n", 2); e.Fields["n"] != int64(2) {
+		t.Errorf("got %s, want n=2", e)
+	}
+	// Entries captured before the call count too.
+	l.WaitFor(slog.LevelInfo, "tick", 0, "n", 2)
+}
+
+func TestLoggerDump(t *testing.T) {
+	r := run(t, func(l *daemontest.Logger) {
+		l.Named("gw").Info("tick", "n", 1)
+		l.Dump()
+	})
+
+	if len(r.logs) != 1 {
+		t.Fatalf("got logs %q, want 1", r.logs)
+	}
+	if want := "1 captured log entries:\nINFO tick logger=gw n=1"; r.logs[0] != want {
+		t.Errorf("got %q, want %q", r.logs[0], want)
+	}
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorClass describes how an error should be treated by retrying callers.
+type ErrorClass int
+
+const (
+	// ClassUnknown is the class of errors that have not been classified.
+	// Callers treat them as transient.
+	ClassUnknown ErrorClass = iota
+	// ClassTransient errors are expected to go away on their own (a broker
+	// hiccup, a dropped connection) and are worth retrying.
+	ClassTransient
+	// ClassPermanent errors will not go away by retrying (bad configuration,
+	// a malformed record) and should not be retried.
+	ClassPermanent
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassTransient:
+		return "transient"
+	case ClassPermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+type classifiedError struct {
+	err        error
+	class      ErrorClass
+	retryAfter time.Duration
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: ClassPermanent}
+}
+
+// Transient marks err as worth retrying.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: ClassTransient}
+}
+
+// RetryAfter marks err as transient, and asks that the retry be delayed by
+// at least d.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: ClassTransient, retryAfter: d}
+}
+
+// Classify returns the class of the outermost classified error in err's
+// chain, or ClassUnknown if there is none.
+func Classify(err error) ErrorClass {
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	return ClassUnknown
+}
+
+// IsPermanent reports whether err has been marked as permanent.
+func IsPermanent(err error) bool {
+	return Classify(err) == ClassPermanent
+}
+
+// IsTransient reports whether err should be retried, which is the case for
+// every error that has not been marked as permanent.
+func IsTransient(err error) bool {
+	return err != nil && !IsPermanent(err)
+}
+
+// RetryDelay returns the delay requested by RetryAfter, if any.
+func RetryDelay(err error) (time.Duration, bool) {
+	var ce *classifiedError
+	if errors.As(err, &ce) && ce.retryAfter > 0 {
+		return ce.retryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type levelEntry struct {
+	level slog.Level
+	gen   uint64
+	timer *time.Timer
+
+	// base is the level set without a ttl, which a temporary level
+	// restores when it expires; nil if there was none.
+	base *slog.Level
+}
+
+// LevelRegistry holds the log level of each named logger, and can be
+// changed at runtime. A logger without a level of its own takes the level
+// of its nearest named ancestor ("a.b.c" falls back to "a.b", then "a"),
+// and finally the registry's default, which is registered under "".
+type LevelRegistry struct {
+	mu     sync.RWMutex
+	levels map[string]*levelEntry
+	gen    uint64
+}
+
+func NewLevelRegistry(def slog.Level) *LevelRegistry {
+	return &LevelRegistry{
+		levels: map[string]*levelEntry{"": {level: def}},
+	}
+}
+
+// Level returns the effective level of the logger called name.
+func (r *LevelRegistry) Level(name string) slog.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for {
+		if e, ok := r.levels[name]; ok {
+			return e.level
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+
+	return r.levels[""].level
+}
+
+// Enabled reports whether the logger called name logs at level.
+func (r *LevelRegistry) Enabled(name string, level slog.Level) bool {
+	return level >= r.Level(name)
+}
+
+// Set sets the level of the logger called name, and its descendants.
+func (r *LevelRegistry) Set(name string, level slog.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set(name, level)
+}
+
+// SetFor sets the level of the logger called name for ttl, after which the
+// last level set without a ttl is restored, unless it has been changed
+// again in the meantime. A temporary level replaces any other, so that
+// levels set for a while do not nest.
+func (r *LevelRegistry) SetFor(name string, level slog.Level, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var base *slog.Level
+	if prev, ok := r.levels[name]; ok {
+		base = prev.base
+		if prev.timer == nil {
+			base = &prev.level
+		}
+	}
+
+	e := r.set(name, level)
+	e.base = base
+	gen := e.gen
+
+	e.timer = time.AfterFunc(ttl, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if cur, ok := r.levels[name]; !ok || cur.gen != gen {
+			return
+		}
+		if base != nil {
+			r.set(name, *base)
+		} else {
+			delete(r.levels, name)
+		}
+	})
+}
+
+// Reset removes the level of the logger called name, so that it falls back
+// to its ancestors. The default level cannot be removed.
+func (r *LevelRegistry) Reset(name string) {
+	if name == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.levels[name]; ok {
+		e.stop()
+		delete(r.levels, name)
+	}
+}
+
+// Replace swaps every level for levels, as when reloading configuration.
+// If levels has no default, the current default is kept.
+func (r *LevelRegistry) Replace(levels map[string]slog.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	def := r.levels[""].level
+	for _, e := range r.levels {
+		e.stop()
+	}
+
+	r.levels = map[string]*levelEntry{}
+	r.set("", def)
+	for name, level := range levels {
+		r.set(name, level)
+	}
+}
+
+// Levels returns the levels that have been set, by logger name.
+func (r *LevelRegistry) Levels() map[string]slog.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	levels := make(map[string]slog.Level, len(r.levels))
+	for name, e := range r.levels {
+		levels[name] = e.level
+	}
+	return levels
+}
+
+// set must be called with r.mu held.
+func (r *LevelRegistry) set(name string, level slog.Level) *levelEntry {
+	if e, ok := r.levels[name]; ok {
+		e.stop()
+	}
+
+	r.gen++
+	e := &levelEntry{level: level, gen: r.gen}
+	r.levels[name] = e
+	return e
+}
+
+func (e *levelEntry) stop() {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+}
+
+// ServeHTTP exposes the registry as an admin endpoint. GET lists the levels
+// that have been set; PUT or POST sets the level of the logger named by the
+// "logger" query parameter to the "level" parameter, for the duration in
+// the optional "ttl" parameter; DELETE resets it.
+func (r *LevelRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("logger")
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(req.URL.Query().Get("level"))); err != nil {
+			http.Error(w, fmt.Sprintf("invalid level: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if ttl := req.URL.Query().Get("ttl"); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid ttl: %v", err), http.StatusBadRequest)
+				return
+			}
+			r.SetFor(name, level, d)
+		} else {
+			r.Set(name, level)
+		}
+	case http.MethodDelete:
+		r.Reset(name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	levels := make(map[string]string)
+	for name, level := range r.Levels() {
+		levels[name] = level.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levels)
+}
+
+// LeveledLogger is a Logger that drops entries below the level its name has
+// in a LevelRegistry. The logger it wraps should itself log at every level.
+type LeveledLogger struct {
+	next     Logger
+	registry *LevelRegistry
+	name     string
+}
+
+var (
+	_ Logger       = (*LeveledLogger)(nil)
+	_ LevelEnabler = (*LeveledLogger)(nil)
+)
+
+// Logger wraps logger so that its level, and that of its named children,
+// is controlled by the registry.
+func (r *LevelRegistry) Logger(logger Logger) *LeveledLogger {
+	return &LeveledLogger{next: logger, registry: r}
+}
+
+func (l *LeveledLogger) Enabled(_ context.Context, level slog.Level) bool {
+	return l.registry.Enabled(l.name, level)
+}
+
+func (l *LeveledLogger) Debug(msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelDebug) {
+		l.next.Debug(msg, args...)
+	}
+}
+
+func (l *LeveledLogger) Info(msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelInfo) {
+		l.next.Info(msg, args...)
+	}
+}
+
+func (l *LeveledLogger) Warn(msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelWarn) {
+		l.next.Warn(msg, args...)
+	}
+}
+
+func (l *LeveledLogger) Error(msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelError) {
+		l.next.Error(msg, args...)
+	}
+}
+
+func (l *LeveledLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelDebug) {
+		l.next.DebugContext(ctx, msg, args...)
+	}
+}
+
+func (l *LeveledLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelInfo) {
+		l.next.InfoContext(ctx, msg, args...)
+	}
+}
+
+func (l *LeveledLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelWarn) {
+		l.next.WarnContext(ctx, msg, args...)
+	}
+}
+
+func (l *LeveledLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	if l.registry.Enabled(l.name, slog.LevelError) {
+		l.next.ErrorContext(ctx, msg, args...)
+	}
+}
+
+func (l *LeveledLogger) With(args ...any) Logger {
+	return &LeveledLogger{next: l.next.With(args...), registry: l.registry, name: l.name}
+}
+
+func (l *LeveledLogger) Named(name string) Logger {
+	return &LeveledLogger{next: l.next.Named(name), registry: l.registry, name: joinName(l.name, name)}
+}
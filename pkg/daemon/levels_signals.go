@@ -0,0 +1,44 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// HandleSignals changes levels on signals until ctx is done: SIGUSR1 turns
+// on debug logging everywhere for ttl, lowering the default and every
+// level that has been set, and SIGUSR2 restores the levels as they were
+// when HandleSignals was called.
+func (r *LevelRegistry) HandleSignals(ctx context.Context, ttl time.Duration) {
+	initial := r.Levels()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(sigCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigCh:
+				switch sig {
+				case syscall.SIGUSR1:
+					for name := range r.Levels() {
+						r.SetFor(name, slog.LevelDebug, ttl)
+					}
+				case syscall.SIGUSR2:
+					r.Replace(maps.Clone(initial))
+				}
+			}
+		}
+	}()
+}
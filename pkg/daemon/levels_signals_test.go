@@ -0,0 +1,42 @@
+//go:build !windows
+
+package daemon_test
+
+import (
+	"context"
+	"log/slog"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+)
+
+func TestLevelRegistryDebugSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := daemon.NewLevelRegistry(slog.LevelInfo)
+	r.Set("kafka", slog.LevelWarn)
+	r.HandleSignals(ctx, 100*time.Millisecond)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := waitForLevel(r, "kafka", slog.LevelWarn); got != slog.LevelDebug {
+		t.Fatalf("got kafka level %s after SIGUSR1, want %s", got, slog.LevelDebug)
+	}
+	for _, name := range []string{"", "gateway", "kafka.client"} {
+		if got := r.Level(name); got != slog.LevelDebug {
+			t.Errorf("got %q level %s after SIGUSR1, want %s", name, got, slog.LevelDebug)
+		}
+	}
+
+	if got := waitForLevel(r, "kafka", slog.LevelDebug); got != slog.LevelWarn {
+		t.Errorf("got kafka level %s after the ttl, want %s", got, slog.LevelWarn)
+	}
+	if got := waitForLevel(r, "", slog.LevelDebug); got != slog.LevelInfo {
+		t.Errorf("got default level %s after the ttl, want %s", got, slog.LevelInfo)
+	}
+}
@@ -0,0 +1,9 @@
+package daemon
+
+import (
+	"context"
+	"time"
+)
+
+// HandleSignals does nothing on Windows, which has no user signals.
+func (r *LevelRegistry) HandleSignals(ctx context.Context, ttl time.Duration) {}
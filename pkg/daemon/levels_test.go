@@ -0,0 +1,43 @@
+package daemon_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+)
+
+func TestLevelRegistryNestedSetForRestoresBase(t *testing.T) {
+	r := daemon.NewLevelRegistry(slog.LevelInfo)
+	r.Set("a", slog.LevelWarn)
+	r.SetFor("a", slog.LevelDebug, time.Hour)
+	r.SetFor("a", slog.LevelError, 10*time.Millisecond)
+
+	if got := waitForLevel(r, "a", slog.LevelError); got != slog.LevelWarn {
+		t.Errorf("got level %s after the ttl, want %s", got, slog.LevelWarn)
+	}
+}
+
+func TestLevelRegistryNestedSetForWithoutBase(t *testing.T) {
+	r := daemon.NewLevelRegistry(slog.LevelInfo)
+	r.SetFor("a", slog.LevelDebug, time.Hour)
+	r.SetFor("a", slog.LevelError, 10*time.Millisecond)
+
+	if got := waitForLevel(r, "a", slog.LevelError); got != slog.LevelInfo {
+		t.Errorf("got level %s after the ttl, want %s", got, slog.LevelInfo)
+	}
+	if _, ok := r.Levels()["a"]; ok {
+		t.Error("temporary level was kept after the ttl")
+	}
+}
+
+// waitForLevel waits for the level of name to change from level, and
+// returns the level it changed to.
+func waitForLevel(r *daemon.LevelRegistry, name string, level slog.Level) slog.Level {
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Level(name) == level && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return r.Level(name)
+}
@@ -1,8 +1,34 @@
 package daemon
 
+import (
+	"context"
+	"log/slog"
+)
+
 type Logger interface {
 	Debug(msg string, args ...any)
 	Info(msg string, args ...any)
 	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
+
+	// The Context variants also record the daemon identity, correlation ID
+	// and trace carried by ctx.
+	DebugContext(ctx context.Context, msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
+
+	// With returns a child logger that adds args, as key/value pairs or
+	// slog.Attrs, to every entry.
+	With(args ...any) Logger
+	// Named returns a child logger whose name is this logger's name with
+	// name appended, separated by a dot.
+	Named(name string) Logger
+}
+
+// LevelEnabler is implemented by Loggers that can tell whether an entry at
+// level would be logged, so that the Loggers wrapping them can skip the
+// work for entries that would be dropped.
+type LevelEnabler interface {
+	Enabled(ctx context.Context, level slog.Level) bool
 }
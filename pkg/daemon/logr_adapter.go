@@ -0,0 +1,44 @@
+package daemon
+
+import "github.com/go-logr/logr"
+
+// LogrSink is a logr.LogSink that writes through a Logger. V-level 0 is
+// logged at Info, and anything more verbose at Debug.
+type LogrSink struct {
+	logger    Logger
+	verbosity int
+}
+
+var _ logr.LogSink = (*LogrSink)(nil)
+
+// NewLogr returns a logr.Logger that writes through logger, and drops
+// entries more verbose than verbosity.
+func NewLogr(logger Logger, verbosity int) logr.Logger {
+	return logr.New(&LogrSink{logger: logger, verbosity: verbosity})
+}
+
+func (l *LogrSink) Init(logr.RuntimeInfo) {}
+
+func (l *LogrSink) Enabled(level int) bool {
+	return level <= l.verbosity
+}
+
+func (l *LogrSink) Info(level int, msg string, keysAndValues ...any) {
+	if level > 0 {
+		l.logger.Debug(msg, append(keysAndValues, "v", level)...)
+		return
+	}
+	l.logger.Info(msg, keysAndValues...)
+}
+
+func (l *LogrSink) Error(err error, msg string, keysAndValues ...any) {
+	l.logger.Error(msg, append(keysAndValues, "error", err)...)
+}
+
+func (l *LogrSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &LogrSink{logger: l.logger.With(keysAndValues...), verbosity: l.verbosity}
+}
+
+func (l *LogrSink) WithName(name string) logr.LogSink {
+	return &LogrSink{logger: l.logger.Named(name), verbosity: l.verbosity}
+}
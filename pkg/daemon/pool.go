@@ -0,0 +1,436 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Identity names the daemon a context was handed to. Replicas of a Pool
+// are numbered by Index; Pooled tells replica 0 apart from a daemon that
+// is not in a pool.
+type Identity struct {
+	Name   string
+	Index  int
+	Pooled bool
+}
+
+func (id Identity) String() string {
+	if !id.Pooled {
+		return id.Name
+	}
+	return fmt.Sprintf("%s/%d", id.Name, id.Index)
+}
+
+// Factory builds the daemon for a single replica.
+type Factory func(id Identity) (Daemon, error)
+
+type ReplicaState string
+
+const (
+	ReplicaPending    ReplicaState = "pending"
+	ReplicaRunning    ReplicaState = "running"
+	ReplicaRestarting ReplicaState = "restarting"
+	ReplicaStopping   ReplicaState = "stopping"
+	ReplicaStopped    ReplicaState = "stopped"
+	ReplicaFailed     ReplicaState = "failed"
+)
+
+// ReplicaEvent is emitted whenever a replica changes state.
+type ReplicaEvent struct {
+	ID    Identity
+	State ReplicaState
+	Err   error
+	At    time.Time
+}
+
+// ReplicaHealth is a point-in-time view of a single replica.
+type ReplicaHealth struct {
+	ID        Identity
+	State     ReplicaState
+	Restarts  int
+	LastError error
+	Since     time.Time
+}
+
+type replica struct {
+	id     Identity
+	daemon Daemon
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+	state    ReplicaState
+	restarts int
+	lastErr  error
+	since    time.Time
+}
+
+// Pool runs a number of replicas of the same daemon, each built by a
+// Factory and each with its own context, identity and restart policy. A
+// Pool is itself a Daemon, so it is handed to Archon like any other.
+type Pool struct {
+	name     string
+	factory  Factory
+	logger   Logger
+	replicas int
+	restart  RetryPolicy
+	onEvent  func(ReplicaEvent)
+
+	scaleMu sync.Mutex
+	mu      sync.Mutex
+	running []*replica
+	next    int
+	runCtx  context.Context
+	errCh   chan error
+}
+
+var _ Daemon = (*Pool)(nil)
+
+type PoolOption func(*Pool)
+
+func WithReplicas(n int) PoolOption {
+	return func(p *Pool) {
+		p.replicas = n
+	}
+}
+
+func WithPoolLogger(logger Logger) PoolOption {
+	return func(p *Pool) {
+		p.logger = logger
+	}
+}
+
+// WithReplicaRestartPolicy sets the restart policy applied to each replica
+// individually.
+func WithReplicaRestartPolicy(policy RetryPolicy) PoolOption {
+	return func(p *Pool) {
+		p.restart = policy
+	}
+}
+
+// OnReplicaEvent registers a callback for replica lifecycle events. It is
+// called synchronously, so it should not block.
+func OnReplicaEvent(fn func(ReplicaEvent)) PoolOption {
+	return func(p *Pool) {
+		p.onEvent = fn
+	}
+}
+
+func NewPool(name string, factory Factory, options ...PoolOption) (*Pool, error) {
+	pool := &Pool{
+		name:     name,
+		factory:  factory,
+		logger:   nil,
+		replicas: 1,
+		restart:  RetryPolicy{MaxRetries: 0, Delay: 1 * time.Second},
+		onEvent:  func(ReplicaEvent) {},
+		errCh:    make(chan error, 1),
+	}
+
+	for _, opt := range options {
+		opt(pool)
+	}
+
+	if pool.logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	if factory == nil {
+		return nil, fmt.Errorf("factory is required")
+	}
+
+	if pool.replicas < 0 {
+		return nil, fmt.Errorf("replica count must not be negative")
+	}
+
+	return pool, nil
+}
+
+func (p *Pool) Setup(ctx context.Context) error {
+	return p.Scale(ctx, p.replicas)
+}
+
+func (p *Pool) Run(ctx context.Context) error {
+	p.mu.Lock()
+	p.runCtx = ctx
+	for _, r := range p.running {
+		p.start(ctx, r)
+	}
+	p.mu.Unlock()
+
+	// Whichever way Run ends, the replicas are stopped, though not shut
+	// down, first; running the pool again, as Archon does to restart it,
+	// starts each of them once more, the failed one included.
+	defer p.halt()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-p.errCh:
+		return err
+	}
+}
+
+// halt stops the replicas' runs and waits for them to return, leaving them
+// set up for the next run.
+func (p *Pool) halt() {
+	p.mu.Lock()
+	p.runCtx = nil
+	replicas := append([]*replica(nil), p.running...)
+	p.mu.Unlock()
+
+	for _, r := range replicas {
+		if cancel, _ := r.run(); cancel != nil {
+			cancel()
+		}
+	}
+	for _, r := range replicas {
+		if _, done := r.run(); done != nil {
+			<-done
+		}
+
+		r.mu.Lock()
+		halted := r.state == ReplicaRunning || r.state == ReplicaRestarting
+		r.mu.Unlock()
+		if halted {
+			p.transition(r, ReplicaPending, nil)
+		}
+	}
+
+	// Drop failures reported in the meantime, so that the next run does
+	// not return them.
+	select {
+	case <-p.errCh:
+	default:
+	}
+}
+
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+
+	p.mu.Lock()
+	replicas := p.running
+	p.running = nil
+	p.mu.Unlock()
+
+	return p.stopAll(ctx, replicas)
+}
+
+// Size returns the current number of replicas.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.running)
+}
+
+// Scale grows or shrinks the pool to n replicas. New replicas are set up,
+// and started if the pool is running; surplus replicas are stopped and shut
+// down, newest first. Other replicas are not disturbed.
+func (p *Pool) Scale(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("replica count must not be negative")
+	}
+
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+
+	p.mu.Lock()
+	current := len(p.running)
+	p.mu.Unlock()
+
+	p.logger.Info("scaling pool", "pool", p.name, "from", current, "to", n)
+
+	for i := current; i < n; i++ {
+		r, err := p.newReplica(ctx)
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		p.running = append(p.running, r)
+		if p.runCtx != nil {
+			p.start(p.runCtx, r)
+		}
+		p.mu.Unlock()
+	}
+
+	if n < current {
+		p.mu.Lock()
+		surplus := p.running[n:]
+		p.running = p.running[:n:n]
+		p.mu.Unlock()
+
+		return p.stopAll(ctx, surplus)
+	}
+
+	return nil
+}
+
+// Health reports the state of every replica.
+func (p *Pool) Health() []ReplicaHealth {
+	p.mu.Lock()
+	replicas := append([]*replica(nil), p.running...)
+	p.mu.Unlock()
+
+	health := make([]ReplicaHealth, 0, len(replicas))
+	for _, r := range replicas {
+		r.mu.Lock()
+		health = append(health, ReplicaHealth{
+			ID:        r.id,
+			State:     r.state,
+			Restarts:  r.restarts,
+			LastError: r.lastErr,
+			Since:     r.since,
+		})
+		r.mu.Unlock()
+	}
+
+	return health
+}
+
+func (p *Pool) newReplica(ctx context.Context) (*replica, error) {
+	p.mu.Lock()
+	id := Identity{Name: p.name, Index: p.next, Pooled: true}
+	p.next++
+	p.mu.Unlock()
+
+	d, err := p.factory(id)
+	if err != nil {
+		return nil, fmt.Errorf("replica %s: factory failed: %w", id, err)
+	}
+
+	r := &replica{id: id, daemon: d}
+	p.transition(r, ReplicaPending, nil)
+
+	if err := d.Setup(ContextWithIdentity(ctx, id)); err != nil {
+		p.transition(r, ReplicaFailed, err)
+		// Release whatever Setup got as far as acquiring.
+		if shutdownErr := d.Shutdown(ContextWithIdentity(ctx, id)); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("shutdown failed: %w", shutdownErr))
+		}
+		return nil, fmt.Errorf("replica %s: setup failed: %w", id, err)
+	}
+
+	return r, nil
+}
+
+// start must be called with p.mu held.
+func (p *Pool) start(ctx context.Context, r *replica) {
+	rctx, cancel := context.WithCancel(ContextWithIdentity(ctx, r.id))
+	done := make(chan struct{})
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.done = done
+	r.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		p.supervise(rctx, r)
+	}()
+}
+
+// run returns what stops r's current run, and is closed when it has; both
+// are nil if r has never been started.
+func (r *replica) run() (context.CancelFunc, chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cancel, r.done
+}
+
+func (p *Pool) supervise(ctx context.Context, r *replica) {
+	var delay time.Duration
+	for restarts := 0; ; restarts++ {
+		p.transition(r, ReplicaRunning, nil)
+		err := runRecovering(ctx, r.daemon)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			p.transition(r, ReplicaStopped, nil)
+			return
+		}
+
+		if !p.restart.Retryable(err, restarts) {
+			p.transition(r, ReplicaFailed, err)
+			select {
+			case p.errCh <- fmt.Errorf("replica %s: %w", r.id, err):
+			default:
+			}
+			return
+		}
+
+		p.transition(r, ReplicaRestarting, err)
+		delay = p.restart.Wait(err, restarts, delay)
+		if !Sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+func (p *Pool) stopAll(ctx context.Context, replicas []*replica) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(replicas))
+	for i, r := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.stop(ctx, r)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (p *Pool) stop(ctx context.Context, r *replica) error {
+	p.transition(r, ReplicaStopping, nil)
+
+	if cancel, done := r.run(); cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			p.transition(r, ReplicaFailed, ctx.Err())
+			return fmt.Errorf("replica %s: did not stop: %w", r.id, ctx.Err())
+		}
+	}
+
+	if err := r.daemon.Shutdown(ContextWithIdentity(ctx, r.id)); err != nil {
+		p.transition(r, ReplicaFailed, err)
+		return fmt.Errorf("replica %s: shutdown failed: %w", r.id, err)
+	}
+
+	p.transition(r, ReplicaStopped, nil)
+	return nil
+}
+
+func (p *Pool) transition(r *replica, state ReplicaState, err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if state == ReplicaRestarting {
+		r.restarts++
+	}
+	r.state = state
+	r.since = now
+	if err != nil {
+		r.lastErr = err
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		p.logger.Warn("replica state changed", "replica", r.id.String(), "state", state, "error", err)
+	} else {
+		p.logger.Info("replica state changed", "replica", r.id.String(), "state", state)
+	}
+
+	p.onEvent(ReplicaEvent{ID: r.id, State: state, Err: err, At: now})
+}
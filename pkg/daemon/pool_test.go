@@ -0,0 +1,195 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+)
+
+// replicas builds replicas that run until stopped, recording which have
+// been shut down.
+type replicas struct {
+	mu       sync.Mutex
+	shutdown []int
+}
+
+func (rs *replicas) factory(id daemon.Identity) (daemon.Daemon, error) {
+	return &blockingReplica{id: id, rs: rs}, nil
+}
+
+func (rs *replicas) shutDown() []int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return slices.Sorted(slices.Values(rs.shutdown))
+}
+
+type blockingReplica struct {
+	id daemon.Identity
+	rs *replicas
+}
+
+func (r *blockingReplica) Setup(context.Context) error { return nil }
+
+func (r *blockingReplica) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (r *blockingReplica) Shutdown(context.Context) error {
+	r.rs.mu.Lock()
+	defer r.rs.mu.Unlock()
+
+	r.rs.shutdown = append(r.rs.shutdown, r.id.Index)
+	return nil
+}
+
+// indexes returns the indexes of the pool's replicas in state, in order.
+func indexes(p *daemon.Pool, state daemon.ReplicaState) []int {
+	var found []int
+	for _, h := range p.Health() {
+		if h.State == state {
+			found = append(found, h.ID.Index)
+		}
+	}
+	return found
+}
+
+func waitRunning(t *testing.T, p *daemon.Pool, want []int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !slices.Equal(indexes(p, daemon.ReplicaRunning), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got running replicas %v, want %v", indexes(p, daemon.ReplicaRunning), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolScaleDown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rs := &replicas{}
+	pool, err := daemon.NewPool("worker", rs.factory, daemon.WithPoolLogger(daemontest.NewLogger(t)), daemon.WithReplicas(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go pool.Run(ctx)
+	waitRunning(t, pool, []int{0, 1, 2, 3})
+
+	steps := []struct {
+		size     int
+		running  []int
+		shutdown []int
+	}{
+		{2, []int{0, 1}, []int{2, 3}},
+		// Indexes are not reused.
+		{4, []int{0, 1, 4, 5}, []int{2, 3}},
+		{1, []int{0}, []int{1, 2, 3, 4, 5}},
+		{0, nil, []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, step := range steps {
+		if err := pool.Scale(ctx, step.size); err != nil {
+			t.Fatal(err)
+		}
+		waitRunning(t, pool, step.running)
+		if got := pool.Size(); got != step.size {
+			t.Errorf("scaled to %d: got size %d", step.size, got)
+		}
+		if got := rs.shutDown(); !slices.Equal(got, step.shutdown) {
+			t.Errorf("scaled to %d: got replicas %v shut down, want %v", step.size, got, step.shutdown)
+		}
+	}
+}
+
+// flakyReplica fails its first runs, then runs until stopped.
+type flakyReplica struct {
+	failures int
+
+	mu   sync.Mutex
+	runs int
+}
+
+func (r *flakyReplica) Setup(context.Context) error { return nil }
+
+func (r *flakyReplica) Run(ctx context.Context) error {
+	r.mu.Lock()
+	r.runs++
+	fail := r.runs <= r.failures
+	r.mu.Unlock()
+
+	if fail {
+		return errors.New("flaky")
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (r *flakyReplica) Shutdown(context.Context) error { return nil }
+
+func TestPoolReplicaRestarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	factory := func(id daemon.Identity) (daemon.Daemon, error) {
+		return &flakyReplica{failures: id.Index}, nil
+	}
+	var mu sync.Mutex
+	var restarting []int
+	pool, err := daemon.NewPool("worker", factory,
+		daemon.WithPoolLogger(daemontest.NewLogger(t)),
+		daemon.WithReplicas(3),
+		daemon.WithReplicaRestartPolicy(daemon.RetryPolicy{MaxRetries: 5, Delay: time.Millisecond}),
+		daemon.OnReplicaEvent(func(e daemon.ReplicaEvent) {
+			if e.State == daemon.ReplicaRestarting {
+				mu.Lock()
+				restarting = append(restarting, e.ID.Index)
+				mu.Unlock()
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go pool.Run(ctx)
+
+	// Replica i runs for good after i restarts.
+	settled := func() bool {
+		for _, h := range pool.Health() {
+			if h.State != daemon.ReplicaRunning || h.Restarts != h.ID.Index {
+				return false
+			}
+		}
+		return true
+	}
+	for deadline := time.Now().Add(time.Second); !settled(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got replicas %+v, want replica i running after i restarts", pool.Health())
+		}
+	}
+
+	for _, h := range pool.Health() {
+		if h.Restarts > 0 && (h.LastError == nil || h.LastError.Error() != "flaky") {
+			t.Errorf("replica %s: got last error %v, want flaky", h.ID, h.LastError)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := slices.Sorted(slices.Values(restarting)); !slices.Equal(got, []int{1, 2, 2}) {
+		t.Errorf("got restarting events for %v, want [1 2 2]", got)
+	}
+}
@@ -0,0 +1,255 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LogEntry is a log entry as kept by RecentLogs.
+type LogEntry struct {
+	Seq     uint64         `json:"seq"`
+	Time    time.Time      `json:"time"`
+	Level   slog.Level     `json:"level"`
+	Logger  string         `json:"logger,omitempty"`
+	Daemon  string         `json:"daemon,omitempty"`
+	Message string         `json:"msg"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// RecentFilter selects entries from RecentLogs. An empty Daemon, and a
+// zero Limit, match everything.
+type RecentFilter struct {
+	// MinLevel drops entries below it. Its zero value is slog.LevelInfo,
+	// so that debug entries are only kept when asked for.
+	MinLevel slog.Level
+	Daemon   string
+	// Limit keeps only the most recent entries.
+	Limit int
+}
+
+// RecentLogs keeps the last entries logged through the loggers it wraps,
+// for diagnostics and crash reports. Writers never block one another, or
+// readers: each entry is published into its slot with a single atomic
+// store.
+type RecentLogs struct {
+	slots []atomic.Pointer[LogEntry]
+	next  atomic.Uint64
+}
+
+// NewRecentLogs keeps the last size entries.
+func NewRecentLogs(size int) *RecentLogs {
+	if size < 1 {
+		size = 1
+	}
+	return &RecentLogs{slots: make([]atomic.Pointer[LogEntry], size)}
+}
+
+func (r *RecentLogs) add(e *LogEntry) {
+	e.Seq = r.next.Add(1) - 1
+	r.slots[e.Seq%uint64(len(r.slots))].Store(e)
+}
+
+// Entries returns the entries matching filter, oldest first.
+func (r *RecentLogs) Entries(filter RecentFilter) []LogEntry {
+	end := r.next.Load()
+	start := uint64(0)
+	if size := uint64(len(r.slots)); end > size {
+		start = end - size
+	}
+
+	entries := make([]LogEntry, 0, end-start)
+	for seq := start; seq < end; seq++ {
+		e := r.slots[seq%uint64(len(r.slots))].Load()
+		// Skip slots that have been overwritten, or not yet written.
+		if e == nil || e.Seq != seq {
+			continue
+		}
+		if e.Level < filter.MinLevel || (filter.Daemon != "" && e.Daemon != filter.Daemon) {
+			continue
+		}
+		entries = append(entries, *e)
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries
+}
+
+// ServeHTTP exposes the entries as JSON. The "level", "daemon" and "limit"
+// query parameters fill in the RecentFilter.
+func (r *RecentLogs) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := RecentFilter{MinLevel: slog.LevelDebug, Daemon: req.URL.Query().Get("daemon")}
+	if level := req.URL.Query().Get("level"); level != "" {
+		if err := filter.MinLevel.UnmarshalText([]byte(level)); err != nil {
+			http.Error(w, fmt.Sprintf("invalid level: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid limit: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Entries(filter))
+}
+
+// Logger wraps logger so that what it logs is also kept. Entries are
+// served over HTTP and written to crash reports, so they are redacted as
+// the adapters would: give WithRedactor the Redactor logger was built
+// with.
+func (r *RecentLogs) Logger(logger Logger, options ...AdapterOption) *RecentLogger {
+	c := newAdapterConfig(options)
+	return &RecentLogger{next: logger, recent: r, redactor: c.redactor}
+}
+
+// RecentLogger is a Logger that keeps a copy of its entries in RecentLogs.
+// If the Logger it wraps is a LevelEnabler, entries it would drop are not
+// kept either.
+type RecentLogger struct {
+	next     Logger
+	recent   *RecentLogs
+	redactor *Redactor
+	name     string
+	args     []any
+}
+
+var (
+	_ Logger       = (*RecentLogger)(nil)
+	_ LevelEnabler = (*RecentLogger)(nil)
+)
+
+func (l *RecentLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	if e, ok := l.next.(LevelEnabler); ok {
+		return e.Enabled(ctx, level)
+	}
+	return true
+}
+
+func (l *RecentLogger) Debug(msg string, args ...any) {
+	l.keep(nil, slog.LevelDebug, msg, args)
+	l.next.Debug(msg, args...)
+}
+
+func (l *RecentLogger) Info(msg string, args ...any) {
+	l.keep(nil, slog.LevelInfo, msg, args)
+	l.next.Info(msg, args...)
+}
+
+func (l *RecentLogger) Warn(msg string, args ...any) {
+	l.keep(nil, slog.LevelWarn, msg, args)
+	l.next.Warn(msg, args...)
+}
+
+func (l *RecentLogger) Error(msg string, args ...any) {
+	l.keep(nil, slog.LevelError, msg, args)
+	l.next.Error(msg, args...)
+}
+
+func (l *RecentLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.keep(ctx, slog.LevelDebug, msg, args)
+	l.next.DebugContext(ctx, msg, args...)
+}
+
+func (l *RecentLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.keep(ctx, slog.LevelInfo, msg, args)
+	l.next.InfoContext(ctx, msg, args...)
+}
+
+func (l *RecentLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.keep(ctx, slog.LevelWarn, msg, args)
+	l.next.WarnContext(ctx, msg, args...)
+}
+
+func (l *RecentLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.keep(ctx, slog.LevelError, msg, args)
+	l.next.ErrorContext(ctx, msg, args...)
+}
+
+func (l *RecentLogger) With(args ...any) Logger {
+	l2 := *l
+	l2.next = l.next.With(args...)
+	l2.args = append(append([]any(nil), l.args...), args...)
+	return &l2
+}
+
+func (l *RecentLogger) Named(name string) Logger {
+	l2 := *l
+	l2.next = l.next.Named(name)
+	l2.name = joinName(l.name, name)
+	return &l2
+}
+
+func (l *RecentLogger) keep(ctx context.Context, level slog.Level, msg string, args []any) {
+	if !l.Enabled(cmp.Or(ctx, context.Background()), level) {
+		return
+	}
+
+	// Let slog pair the args up, so that they read the same as in the log.
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	if ctx != nil {
+		record.Add(ContextArgs(ctx)...)
+	}
+	record.Add(l.redactor.Args(l.args)...)
+	record.Add(l.redactor.Args(args)...)
+
+	e := &LogEntry{
+		Time:    record.Time,
+		Level:   level,
+		Logger:  l.name,
+		Message: msg,
+	}
+
+	if record.NumAttrs() > 0 {
+		e.Fields = make(map[string]any, record.NumAttrs())
+		record.Attrs(func(a slog.Attr) bool {
+			e.Fields[a.Key] = attrValue(a.Value)
+			return true
+		})
+	}
+
+	if d, ok := e.Fields[DaemonKey].(string); ok {
+		e.Daemon = d
+	}
+
+	l.recent.add(e)
+}
+
+// attrValue turns v into something that encodes sensibly as JSON.
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		m := make(map[string]any, len(v.Group()))
+		for _, a := range v.Group() {
+			m[a.Key] = attrValue(a.Value)
+		}
+		return m
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return v.Any()
+	case slog.KindDuration:
+		return v.Duration().String()
+	default:
+		return v.Any()
+	}
+}
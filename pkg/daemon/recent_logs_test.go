@@ -0,0 +1,63 @@
+package daemon_test
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+)
+
+func TestRecentFilter(t *testing.T) {
+	recent := daemon.NewRecentLogs(10)
+	logger := recent.Logger(daemontest.NewLogger(t))
+	ticker := logger.With(daemon.DaemonKey, "ticker")
+	tocker := logger.With(daemon.DaemonKey, "tocker")
+
+	ticker.Debug("tick debug")
+	ticker.Info("tick info")
+	tocker.Warn("tock warn")
+	ticker.Error("tick error")
+	tocker.Debug("tock debug")
+
+	tests := []struct {
+		name   string
+		filter daemon.RecentFilter
+		want   []string
+	}{
+		{"zero value", daemon.RecentFilter{}, []string{"tick info", "tock warn", "tick error"}},
+		{"debug", daemon.RecentFilter{MinLevel: slog.LevelDebug}, []string{"tick debug", "tick info", "tock warn", "tick error", "tock debug"}},
+		{"warn", daemon.RecentFilter{MinLevel: slog.LevelWarn}, []string{"tock warn", "tick error"}},
+		{"daemon", daemon.RecentFilter{MinLevel: slog.LevelDebug, Daemon: "tocker"}, []string{"tock warn", "tock debug"}},
+		{"limit", daemon.RecentFilter{MinLevel: slog.LevelDebug, Limit: 2}, []string{"tick error", "tock debug"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range recent.Entries(tt.filter) {
+				got = append(got, e.Message)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecentLogsKeepsLastEntries(t *testing.T) {
+	recent := daemon.NewRecentLogs(3)
+	logger := recent.Logger(daemontest.NewLogger(t))
+
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		logger.Info(msg)
+	}
+
+	var got []string
+	for _, e := range recent.Entries(daemon.RecentFilter{}) {
+		got = append(got, e.Message)
+	}
+	if want := []string{"three", "four", "five"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
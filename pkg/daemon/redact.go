@@ -0,0 +1,278 @@
+package daemon
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// Redacted is what secrets are logged as.
+const Redacted = "***"
+
+// Secret is a string that is never logged, printed or marshalled as
+// itself; it renders as Redacted. Use Reveal to get at the value.
+type Secret string
+
+func (s Secret) Reveal() string { return string(s) }
+
+func (Secret) String() string { return Redacted }
+
+func (Secret) GoString() string { return Redacted }
+
+func (Secret) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+func (Secret) MarshalJSON() ([]byte, error) { return json.Marshal(Redacted) }
+
+// DefaultSecretKeys matches the keys, and struct field names, that
+// DefaultRedactor treats as secret.
+const DefaultSecretKeys = `(?i)(password|passwd|passphrase|secret|token|api[_-]?key|credential|private[_-]?key|authorization|cookie)`
+
+// DefaultRedactor is the Redactor the logger adapters use unless told
+// otherwise.
+var DefaultRedactor = MustRedactor(DefaultSecretKeys)
+
+// Redactor hides secrets from log entries. A value is redacted if its key
+// matches one of the Redactor's patterns, if it is a struct field tagged
+// `secret:""`, or if it is a Secret. Structs and maps are logged as
+// groups, so that what they contain is redacted too.
+//
+// Values that render themselves, through a String or MarshalJSON method,
+// are left to do so unless their type has fields the Redactor would hide;
+// those are logged as groups like any other struct. Secrets such a value
+// keeps in a map, or in an unexported field, are not found.
+type Redactor struct {
+	patterns []*regexp.Regexp
+	// keys caches whether each key seen so far is secret, and types
+	// whether each struct type seen so far has secret fields.
+	keys  sync.Map
+	types sync.Map
+}
+
+// NewRedactor returns a Redactor that treats keys matching any of
+// patterns as secret.
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret key pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// MustRedactor is like NewRedactor, but panics if a pattern is invalid.
+func MustRedactor(patterns ...string) *Redactor {
+	r, err := NewRedactor(patterns...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// IsSecret reports whether values logged under key are redacted.
+func (r *Redactor) IsSecret(key string) bool {
+	if r == nil {
+		return false
+	}
+	if secret, ok := r.keys.Load(key); ok {
+		return secret.(bool)
+	}
+
+	secret := slices.ContainsFunc(r.patterns, func(re *regexp.Regexp) bool {
+		return re.MatchString(key)
+	})
+	r.keys.Store(key, secret)
+	return secret
+}
+
+// Args returns args, paired up as slog pairs them, with secrets redacted.
+// args itself is left alone.
+func (r *Redactor) Args(args []any) []any {
+	if r == nil || len(args) == 0 {
+		return args
+	}
+
+	out := make([]any, 0, len(args))
+	for len(args) > 0 {
+		switch key := args[0].(type) {
+		case slog.Attr:
+			out = append(out, r.Attr(key))
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				out = append(out, key)
+				args = args[1:]
+				continue
+			}
+			out = append(out, r.Attr(slog.Any(key, args[1])))
+			args = args[2:]
+		default:
+			out = append(out, key)
+			args = args[1:]
+		}
+	}
+	return out
+}
+
+// Attr returns attr with secrets redacted.
+func (r *Redactor) Attr(attr slog.Attr) slog.Attr {
+	return r.attr(attr, 0)
+}
+
+// maxRedactDepth bounds how deep a Redactor looks into values, which may
+// be cyclic. Anything deeper is redacted.
+const maxRedactDepth = 16
+
+func (r *Redactor) attr(attr slog.Attr, depth int) slog.Attr {
+	if r.IsSecret(attr.Key) || depth > maxRedactDepth {
+		return slog.String(attr.Key, Redacted)
+	}
+	return slog.Attr{Key: attr.Key, Value: r.value(attr.Value, depth)}
+}
+
+func (r *Redactor) value(v slog.Value, depth int) slog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			redacted[i] = r.attr(a, depth+1)
+		}
+		return slog.GroupValue(redacted...)
+	case slog.KindAny:
+		return r.any(v.Any(), depth)
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) any(v any, depth int) slog.Value {
+	rv := reflect.ValueOf(v)
+	switch v.(type) {
+	case nil, error:
+		return slog.AnyValue(v)
+	case fmt.Stringer, json.Marshaler:
+		// These render themselves, which would get around the rules for
+		// their fields, so only leave them to it if they have none.
+		if !r.hasSecretFields(rv.Type()) {
+			return slog.AnyValue(v)
+		}
+	}
+
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.AnyValue(v)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return r.structValue(rv, depth)
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return r.mapValue(rv, depth)
+		}
+	case reflect.Slice, reflect.Array:
+		if redactable(rv.Type().Elem()) {
+			return r.sliceValue(rv, depth)
+		}
+	}
+	return slog.AnyValue(v)
+}
+
+// hasSecretFields reports whether t is a struct, or points to one, with
+// exported fields the Redactor hides, directly or in the structs it holds.
+func (r *Redactor) hasSecretFields(t reflect.Type) bool {
+	if secret, ok := r.types.Load(t); ok {
+		return secret.(bool)
+	}
+	secret := r.secretFields(t, make(map[reflect.Type]bool))
+	r.types.Store(t, secret)
+	return secret
+}
+
+func (r *Redactor) secretFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if _, tagged := f.Tag.Lookup("secret"); tagged || r.IsSecret(f.Name) || r.secretFields(f.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) structValue(rv reflect.Value, depth int) slog.Value {
+	t := rv.Type()
+	attrs := make([]slog.Attr, 0, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if _, secret := f.Tag.Lookup("secret"); secret {
+			attrs = append(attrs, slog.String(f.Name, Redacted))
+			continue
+		}
+		attrs = append(attrs, r.attr(slog.Any(f.Name, rv.Field(i).Interface()), depth+1))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (r *Redactor) mapValue(rv reflect.Value, depth int) slog.Value {
+	keys := rv.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, r.attr(slog.Any(k.String(), rv.MapIndex(k).Interface()), depth+1))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// sliceValue redacts each element. Groups cannot be nested in lists, so
+// elements that become groups are turned into maps.
+func (r *Redactor) sliceValue(rv reflect.Value, depth int) slog.Value {
+	elems := make([]any, rv.Len())
+	for i := range elems {
+		elems[i] = attrValue(r.any(rv.Index(i).Interface(), depth+1))
+	}
+	return slog.AnyValue(elems)
+}
+
+// redactable reports whether values of type t can hold secrets that a
+// Redactor would find.
+func redactable(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		return redactable(t.Elem())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,75 @@
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+)
+
+type credentials struct {
+	User  string
+	Token string
+	Pin   string `secret:""`
+}
+
+func (c credentials) String() string {
+	return c.User + ":" + c.Token + ":" + c.Pin
+}
+
+func (c credentials) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func TestRedactorStringerWithSecretFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := daemon.NewSlogAdapter(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	logger.Info("login", "creds", credentials{User: "ann", Token: "t0k3n", Pin: "1234"})
+
+	out := buf.String()
+	for _, secret := range []string{"t0k3n", "1234"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q logged: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"User":"ann"`) {
+		t.Errorf("non-secret field not logged: %s", out)
+	}
+}
+
+func TestRedactorStringerWithoutSecretFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := daemon.NewSlogAdapter(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logger.Info("tick", "at", at)
+
+	if !strings.Contains(buf.String(), `"at":"2024-01-02T03:04:05Z"`) {
+		t.Errorf("stringer not left to render itself: %s", buf.String())
+	}
+}
+
+func TestRecentLoggerUsesConfiguredRedactor(t *testing.T) {
+	redactor := daemon.MustRedactor(daemon.DefaultSecretKeys, `(?i)^account$`)
+	recent := daemon.NewRecentLogs(10)
+	next := daemon.NewSlogAdapter(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), daemon.WithRedactor(redactor))
+	logger := recent.Logger(next, daemon.WithRedactor(redactor))
+
+	logger.Info("charged", "account", "12345678", "amount", 10)
+
+	entries := recent.Entries(daemon.RecentFilter{})
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if got := entries[0].Fields["account"]; got != daemon.Redacted {
+		t.Errorf("account = %v, want %q", got, daemon.Redacted)
+	}
+	if got := entries[0].Fields["amount"]; got != int64(10) {
+		t.Errorf("amount = %v (%T), want 10", got, got)
+	}
+}
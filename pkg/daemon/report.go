@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DaemonReport summarises the lifetime of a single daemon run by an Archon.
+type DaemonReport struct {
+	Name     string
+	Setup    time.Duration
+	Uptime   time.Duration
+	Shutdown time.Duration
+	Restarts int
+	Err      error
+}
+
+// ShutdownReport summarises a complete Archon run. Err joins the errors of
+// every daemon. If the run failed and the Archon keeps recent logs, they are
+// included too.
+type ShutdownReport struct {
+	Daemons    []DaemonReport
+	Shutdown   time.Duration
+	Err        error
+	RecentLogs []LogEntry
+}
+
+func (r DaemonReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name     string `json:"name"`
+		Setup    string `json:"setup"`
+		Uptime   string `json:"uptime"`
+		Shutdown string `json:"shutdown"`
+		Restarts int    `json:"restarts"`
+		Err      string `json:"error,omitempty"`
+	}{r.Name, r.Setup.String(), r.Uptime.String(), r.Shutdown.String(), r.Restarts, errString(r.Err)})
+}
+
+func (r ShutdownReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Daemons    []DaemonReport `json:"daemons"`
+		Shutdown   string         `json:"shutdown"`
+		Err        string         `json:"error,omitempty"`
+		RecentLogs []LogEntry     `json:"recent_logs,omitempty"`
+	}{r.Daemons, r.Shutdown.String(), errString(r.Err), r.RecentLogs})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// daemonRecord collects a DaemonReport while the daemon is running.
+type daemonRecord struct {
+	mu      sync.Mutex
+	report  DaemonReport
+	started time.Time
+	stopped bool
+	errs    []error
+}
+
+func newDaemonRecord(name string) *daemonRecord {
+	return &daemonRecord{report: DaemonReport{Name: name}}
+}
+
+func (r *daemonRecord) setupDone(d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Setup = d
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+func (r *daemonRecord) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.started = time.Now()
+}
+
+func (r *daemonRecord) restart() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Restarts++
+}
+
+func (r *daemonRecord) stop(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started.IsZero() && !r.stopped {
+		r.report.Uptime = time.Since(r.started)
+		r.stopped = true
+	}
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+func (r *daemonRecord) shutdownDone(d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Shutdown = d
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+func (r *daemonRecord) snapshot() DaemonReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := r.report
+	if !r.started.IsZero() && !r.stopped {
+		report.Uptime = time.Since(r.started)
+	}
+	report.Err = errors.Join(r.errs...)
+
+	return report
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff decides how long to wait before a retry. attempt counts the
+// retries made so far, from zero, and prev is the delay before the last
+// one, or zero before the first.
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// ConstantBackoff waits the same time before every retry.
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+func (b ConstantBackoff) Next(int, time.Duration) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff waits Initial before the first retry, multiplying the
+// wait by Multiplier (2 if unset) for each one after, up to Max if set.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (b ExponentialBackoff) Next(attempt int, _ time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	d := float64(b.Initial)
+	for range attempt {
+		d *= multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			return b.Max
+		}
+		if d >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+	}
+	return time.Duration(d)
+}
+
+// DecorrelatedJitterBackoff waits a random time between Base (100ms if
+// unset) and three times the previous wait, capped at Max, so that clients
+// retrying at once spread out.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (b DecorrelatedJitterBackoff) Next(_ int, prev time.Duration) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		// A zero base would never grow, retrying without pause.
+		base = 100 * time.Millisecond
+	}
+
+	upper := time.Duration(math.MaxInt64)
+	if prev := max(prev, base); prev < upper/3 {
+		upper = 3 * prev
+	}
+	if b.Max > 0 && upper > b.Max {
+		upper = b.Max
+	}
+	if upper <= base {
+		return upper
+	}
+	return base + rand.N(upper-base)
+}
+
+// RetryPolicy controls how many times a failing step is retried, and how
+// long to wait between attempts: Backoff decides if it is set, and
+// otherwise Delay is waited before every retry. Errors marked with
+// Permanent are never retried, and errors marked with RetryAfter override
+// both. A negative MaxRetries retries without limit.
+type RetryPolicy struct {
+	MaxRetries int
+	Delay      time.Duration
+	Backoff    Backoff
+}
+
+// Retryable reports whether err should be retried, after retries retries.
+func (p RetryPolicy) Retryable(err error, retries int) bool {
+	return !IsPermanent(err) && (p.MaxRetries < 0 || retries < p.MaxRetries)
+}
+
+// Wait returns how long to wait before retrying err, after retries
+// retries; prev is the wait before the last one, or zero.
+func (p RetryPolicy) Wait(err error, retries int, prev time.Duration) time.Duration {
+	if d, ok := RetryDelay(err); ok {
+		return d
+	}
+	if p.Backoff == nil {
+		return p.Delay
+	}
+	return p.Backoff.Next(retries, prev)
+}
+
+// Sleep waits for d, returning false if ctx is done first.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
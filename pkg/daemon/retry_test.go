@@ -0,0 +1,123 @@
+package daemon_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff daemon.ExponentialBackoff
+		want    []time.Duration
+	}{
+		{"doubles by default", daemon.ExponentialBackoff{Initial: time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{"multiplier", daemon.ExponentialBackoff{Initial: time.Second, Multiplier: 3}, []time.Duration{time.Second, 3 * time.Second, 9 * time.Second}},
+		{"capped", daemon.ExponentialBackoff{Initial: time.Second, Max: 5 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for attempt, want := range tt.want {
+				if got := tt.backoff.Next(attempt, 0); got != want {
+					t.Errorf("attempt %d: got %s, want %s", attempt, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		if got := (daemon.ExponentialBackoff{Initial: time.Hour}).Next(100, 0); got != math.MaxInt64 {
+			t.Errorf("got %s, want the longest duration", got)
+		}
+	})
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  daemon.DecorrelatedJitterBackoff
+		min, max time.Duration
+	}{
+		{"uncapped", daemon.DecorrelatedJitterBackoff{Base: 10 * time.Millisecond}, 10 * time.Millisecond, time.Duration(math.MaxInt64)},
+		{"capped", daemon.DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: time.Second}, 10 * time.Millisecond, time.Second},
+		{"no base", daemon.DecorrelatedJitterBackoff{Max: time.Second}, 100 * time.Millisecond, time.Second},
+		{"max below base", daemon.DecorrelatedJitterBackoff{Base: time.Second, Max: time.Millisecond}, time.Millisecond, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev time.Duration
+			for attempt := range 50 {
+				d := tt.backoff.Next(attempt, prev)
+				if d < tt.min || d > tt.max {
+					t.Fatalf("attempt %d: got %s, want between %s and %s", attempt, d, tt.min, tt.max)
+				}
+				if limit := 3 * max(prev, tt.min); limit > 0 && d > limit {
+					t.Fatalf("attempt %d: got %s, more than three times %s", attempt, d, prev)
+				}
+				prev = d
+			}
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		backoff := daemon.DecorrelatedJitterBackoff{Base: time.Second}
+		if got := backoff.Next(0, math.MaxInt64/2); got < time.Second {
+			t.Errorf("got %s, want at least the base", got)
+		}
+	})
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	transient := daemon.Transient(errors.New("flaky"))
+	permanent := daemon.Permanent(errors.New("broken"))
+
+	tests := []struct {
+		name    string
+		policy  daemon.RetryPolicy
+		err     error
+		retries int
+		want    bool
+	}{
+		{"within limit", daemon.RetryPolicy{MaxRetries: 3}, transient, 2, true},
+		{"limit reached", daemon.RetryPolicy{MaxRetries: 3}, transient, 3, false},
+		{"no retries", daemon.RetryPolicy{}, transient, 0, false},
+		{"unclassified", daemon.RetryPolicy{MaxRetries: 1}, errors.New("boom"), 0, true},
+		{"permanent", daemon.RetryPolicy{MaxRetries: 3}, permanent, 0, false},
+		{"unlimited", daemon.RetryPolicy{MaxRetries: -1}, transient, 1000, true},
+		{"unlimited permanent", daemon.RetryPolicy{MaxRetries: -1}, permanent, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Retryable(tt.err, tt.retries); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWait(t *testing.T) {
+	err := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		policy  daemon.RetryPolicy
+		err     error
+		retries int
+		want    time.Duration
+	}{
+		{"delay", daemon.RetryPolicy{Delay: time.Second}, err, 3, time.Second},
+		{"backoff", daemon.RetryPolicy{Delay: time.Second, Backoff: daemon.ExponentialBackoff{Initial: time.Millisecond}}, err, 3, 8 * time.Millisecond},
+		{"retry after", daemon.RetryPolicy{Backoff: daemon.ConstantBackoff{Delay: time.Second}}, daemon.RetryAfter(err, time.Minute), 0, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Wait(tt.err, tt.retries, 0); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sampling limits how often the same message is logged. Within each
+// Interval the first First entries with a given message and level are
+// logged, then every Thereafter-th one; the rest are counted, and a summary
+// of what was suppressed is logged when the interval ends. A Thereafter of
+// zero drops everything after the first First entries. Interval must be
+// positive, since the counts are only reset when an interval ends.
+type Sampling struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+}
+
+type sampleKey struct {
+	level slog.Level
+	msg   string
+}
+
+type sampleCount struct {
+	seen       int
+	suppressed int
+}
+
+type sampler struct {
+	policy Sampling
+	logger Logger
+
+	mu     sync.Mutex
+	counts map[sampleKey]*sampleCount
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// SampledLogger is a Logger that samples its entries according to a
+// Sampling policy. Child loggers created with With and Named share the
+// parent's counts. If the Logger it wraps is a LevelEnabler, entries it
+// would drop are not counted.
+type SampledLogger struct {
+	next    Logger
+	sampler *sampler
+}
+
+var (
+	_ Logger       = (*SampledLogger)(nil)
+	_ LevelEnabler = (*SampledLogger)(nil)
+)
+
+// NewSampledLogger wraps logger with sampling. Close must be called to stop
+// the summary goroutine.
+func NewSampledLogger(logger Logger, sampling Sampling) (*SampledLogger, error) {
+	if sampling.Interval <= 0 {
+		return nil, fmt.Errorf("sampling interval must be positive, got %s", sampling.Interval)
+	}
+
+	s := &sampler{
+		policy: sampling,
+		logger: logger,
+		counts: make(map[sampleKey]*sampleCount),
+		stop:   make(chan struct{}),
+	}
+
+	go s.run()
+
+	return &SampledLogger{next: logger, sampler: s}, nil
+}
+
+// Close stops the summary goroutine, and logs a final summary.
+func (l *SampledLogger) Close() {
+	l.sampler.once.Do(func() {
+		close(l.sampler.stop)
+		l.sampler.flush()
+	})
+}
+
+func (l *SampledLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	if e, ok := l.next.(LevelEnabler); ok {
+		return e.Enabled(ctx, level)
+	}
+	return true
+}
+
+// allow reports whether an entry is to be logged. Entries the wrapped
+// logger would drop are not counted, so that they neither use up the
+// sampling nor show up in the summaries.
+func (l *SampledLogger) allow(ctx context.Context, level slog.Level, msg string) bool {
+	return l.Enabled(cmp.Or(ctx, context.Background()), level) && l.sampler.allow(level, msg)
+}
+
+func (l *SampledLogger) Debug(msg string, args ...any) {
+	if l.allow(nil, slog.LevelDebug, msg) {
+		l.next.Debug(msg, args...)
+	}
+}
+
+func (l *SampledLogger) Info(msg string, args ...any) {
+	if l.allow(nil, slog.LevelInfo, msg) {
+		l.next.Info(msg, args...)
+	}
+}
+
+func (l *SampledLogger) Warn(msg string, args ...any) {
+	if l.allow(nil, slog.LevelWarn, msg) {
+		l.next.Warn(msg, args...)
+	}
+}
+
+func (l *SampledLogger) Error(msg string, args ...any) {
+	if l.allow(nil, slog.LevelError, msg) {
+		l.next.Error(msg, args...)
+	}
+}
+
+func (l *SampledLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	if l.allow(ctx, slog.LevelDebug, msg) {
+		l.next.DebugContext(ctx, msg, args...)
+	}
+}
+
+func (l *SampledLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	if l.allow(ctx, slog.LevelInfo, msg) {
+		l.next.InfoContext(ctx, msg, args...)
+	}
+}
+
+func (l *SampledLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	if l.allow(ctx, slog.LevelWarn, msg) {
+		l.next.WarnContext(ctx, msg, args...)
+	}
+}
+
+func (l *SampledLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	if l.allow(ctx, slog.LevelError, msg) {
+		l.next.ErrorContext(ctx, msg, args...)
+	}
+}
+
+func (l *SampledLogger) With(args ...any) Logger {
+	return &SampledLogger{next: l.next.With(args...), sampler: l.sampler}
+}
+
+func (l *SampledLogger) Named(name string) Logger {
+	return &SampledLogger{next: l.next.Named(name), sampler: l.sampler}
+}
+
+func (s *sampler) allow(level slog.Level, msg string) bool {
+	key := sampleKey{level: level, msg: msg}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counts[key]
+	if !ok {
+		c = &sampleCount{}
+		s.counts[key] = c
+	}
+	c.seen++
+
+	if c.seen <= s.policy.First {
+		return true
+	}
+	if s.policy.Thereafter > 0 && (c.seen-s.policy.First)%s.policy.Thereafter == 0 {
+		return true
+	}
+
+	c.suppressed++
+	return false
+}
+
+func (s *sampler) run() {
+	ticker := time.NewTicker(s.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush starts a new interval, logging a summary for every message that
+// was suppressed in the one that ended.
+func (s *sampler) flush() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = make(map[sampleKey]*sampleCount, len(counts))
+	s.mu.Unlock()
+
+	for key, c := range counts {
+		if c.suppressed == 0 {
+			continue
+		}
+		s.logger.Warn("suppressed log entries", "message", key.msg, "level", key.level.String(), "suppressed", c.suppressed, "seen", c.seen, "interval", s.policy.Interval)
+	}
+}
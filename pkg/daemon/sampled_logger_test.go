@@ -0,0 +1,73 @@
+package daemon_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+)
+
+func TestSampledLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      int
+		thereafter int
+		logged     int
+	}{
+		{"first only", 2, 0, 2},
+		{"every third after the first", 2, 3, 4},
+		{"nothing but every fifth", 0, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := daemontest.NewLogger(t)
+			logger, err := daemon.NewSampledLogger(capture, daemon.Sampling{Interval: time.Hour, First: tt.first, Thereafter: tt.thereafter})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range 10 {
+				logger.Warn("hot", "i", i)
+			}
+			// Other messages are counted apart.
+			logger.Warn("cold")
+			logger.Close()
+
+			if got := len(capture.Find(slog.LevelWarn, "hot")); got != tt.logged {
+				t.Errorf("logged %d of 10 entries, want %d", got, tt.logged)
+			}
+			if tt.first > 0 {
+				capture.AssertLogged(slog.LevelWarn, "cold")
+			}
+			capture.AssertLogged(slog.LevelWarn, "suppressed log entries", "message", "hot", "suppressed", 10-tt.logged, "seen", 10)
+		})
+	}
+}
+
+func TestSampledLoggerSkipsDisabledLevels(t *testing.T) {
+	capture := daemontest.NewLogger(t)
+	levels := daemon.NewLevelRegistry(slog.LevelInfo)
+	logger, err := daemon.NewSampledLogger(levels.Logger(capture), daemon.Sampling{Interval: time.Hour, First: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 5 {
+		logger.Debug("noisy")
+	}
+	// Turning debug on now finds the sampling unused.
+	levels.Set("", slog.LevelDebug)
+	logger.Debug("noisy")
+	logger.Close()
+
+	capture.AssertLogged(slog.LevelDebug, "noisy")
+	capture.AssertNotLogged("suppressed log entries")
+}
+
+func TestNewSampledLoggerRequiresInterval(t *testing.T) {
+	if _, err := daemon.NewSampledLogger(daemontest.NewLogger(t), daemon.Sampling{First: 1}); err == nil {
+		t.Error("sampling without an interval was accepted")
+	}
+}
@@ -1,29 +1,109 @@
 package daemon
 
-import "log/slog"
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LoggerNameKey is the key under which SlogAdapter records a logger's name.
+const LoggerNameKey = "logger"
 
 type SlogAdapter struct {
-	logger *slog.Logger
+	base     *slog.Logger
+	logger   *slog.Logger
+	name     string
+	redactor *Redactor
+	source   bool
 }
 
 var _ Logger = (*SlogAdapter)(nil)
 
-func NewSlogAdapter(logger *slog.Logger) *SlogAdapter {
-	return &SlogAdapter{logger: logger}
+func NewSlogAdapter(logger *slog.Logger, options ...AdapterOption) *SlogAdapter {
+	c := newAdapterConfig(options)
+	return &SlogAdapter{base: logger, logger: logger, redactor: c.redactor, source: c.source}
 }
 
 func (s *SlogAdapter) Debug(msg string, args ...any) {
-	s.logger.Debug(msg, args...)
+	s.log(context.Background(), slog.LevelDebug, msg, args)
 }
 
 func (s *SlogAdapter) Info(msg string, args ...any) {
-	s.logger.Info(msg, args...)
+	s.log(context.Background(), slog.LevelInfo, msg, args)
 }
 
 func (s *SlogAdapter) Warn(msg string, args ...any) {
-	s.logger.Warn(msg, args...)
+	s.log(context.Background(), slog.LevelWarn, msg, args)
 }
 
 func (s *SlogAdapter) Error(msg string, args ...any) {
-	s.logger.Error(msg, args...)
+	s.log(context.Background(), slog.LevelError, msg, args)
+}
+
+func (s *SlogAdapter) DebugContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, slog.LevelDebug, msg, append(ContextArgs(ctx), args...))
+}
+
+func (s *SlogAdapter) InfoContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, slog.LevelInfo, msg, append(ContextArgs(ctx), args...))
+}
+
+func (s *SlogAdapter) WarnContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, slog.LevelWarn, msg, append(ContextArgs(ctx), args...))
+}
+
+func (s *SlogAdapter) ErrorContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, slog.LevelError, msg, append(ContextArgs(ctx), args...))
+}
+
+// log builds the record itself, rather than going through slog.Logger, so
+// that the source location, if it is wanted, is that of the adapter's
+// caller.
+func (s *SlogAdapter) log(ctx context.Context, level slog.Level, msg string, args []any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+
+	var pc uintptr
+	if s.source {
+		pc = callerPC()
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, pc)
+	record.Add(s.redactor.Args(args)...)
+	_ = s.logger.Handler().Handle(ctx, record)
+}
+
+func (s *SlogAdapter) With(args ...any) Logger {
+	return s.named(s.base.With(s.redactor.Args(args)...), s.name)
+}
+
+func (s *SlogAdapter) Named(name string) Logger {
+	return s.named(s.base, joinName(s.name, name))
+}
+
+// named returns a child of s logging to base under name. The name is kept
+// out of base, so that naming a logger twice does not record the name
+// twice.
+func (s *SlogAdapter) named(base *slog.Logger, name string) *SlogAdapter {
+	logger := base
+	if name != "" {
+		logger = base.With(LoggerNameKey, name)
+	}
+
+	return &SlogAdapter{base: base, logger: logger, name: name, redactor: s.redactor, source: s.source}
+}
+
+func joinName(parent, name string) string {
+	switch {
+	case parent == "":
+		return name
+	case name == "":
+		return parent
+	default:
+		return parent + "." + name
+	}
 }
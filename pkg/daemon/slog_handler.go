@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// SlogHandler is a slog.Handler that writes through a Logger, so that
+// libraries logging with slog end up in the same pipeline as everything
+// else.
+type SlogHandler struct {
+	logger Logger
+	level  slog.Leveler
+	groups []group
+}
+
+// group is a group opened by WithGroup, along with the attributes added to
+// it since.
+type group struct {
+	name  string
+	attrs []slog.Attr
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler returns a handler that passes records at or above level on
+// to logger. A nil level means slog.LevelInfo.
+func NewSlogHandler(logger Logger, level slog.Leveler) *SlogHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+
+	return &SlogHandler{logger: logger, level: level}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	args := h.grouped(attrs)
+	switch {
+	case record.Level >= slog.LevelError:
+		h.logger.ErrorContext(ctx, record.Message, args...)
+	case record.Level >= slog.LevelWarn:
+		h.logger.WarnContext(ctx, record.Message, args...)
+	case record.Level >= slog.LevelInfo:
+		h.logger.InfoContext(ctx, record.Message, args...)
+	default:
+		h.logger.DebugContext(ctx, record.Message, args...)
+	}
+
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	if len(h.groups) == 0 {
+		return &SlogHandler{logger: h.logger.With(toArgs(attrs)...), level: h.level}
+	}
+
+	groups := slices.Clone(h.groups)
+	last := &groups[len(groups)-1]
+	last.attrs = append(slices.Clip(last.attrs), attrs...)
+
+	return &SlogHandler{logger: h.logger, level: h.level, groups: groups}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{logger: h.logger, level: h.level, groups: append(slices.Clip(h.groups), group{name: name})}
+}
+
+// grouped nests attrs inside the handler's open groups, and returns them as
+// Logger args.
+func (h *SlogHandler) grouped(attrs []slog.Attr) []any {
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		contents := append(slices.Clip(g.attrs), attrs...)
+		if len(contents) == 0 {
+			// Like slog, drop groups that end up empty.
+			attrs = nil
+			continue
+		}
+		attrs = []slog.Attr{{Key: g.name, Value: slog.GroupValue(contents...)}}
+	}
+
+	return toArgs(attrs)
+}
+
+func toArgs(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return args
+}
@@ -1,37 +1,141 @@
 package daemon
 
-import "go.uber.org/zap"
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// badKey is the key slog uses for values that are missing one.
+const badKey = "!BADKEY"
 
 type ZapAdapter struct {
-	logger *zap.Logger
+	logger   *zap.Logger
+	redactor *Redactor
 }
 
 var _ Logger = (*ZapAdapter)(nil)
 
-func NewZapAdapter(logger *zap.Logger) *ZapAdapter {
-	return &ZapAdapter{logger: logger}
+func NewZapAdapter(logger *zap.Logger, options ...AdapterOption) *ZapAdapter {
+	c := newAdapterConfig(options)
+	return &ZapAdapter{logger: logger, redactor: c.redactor}
 }
 
 func (z *ZapAdapter) Debug(msg string, args ...any) {
-	z.logger.Sugar().Debug(msg, toZapFields(args))
+	z.log(zap.DebugLevel, msg, args)
 }
 
 func (z *ZapAdapter) Info(msg string, args ...any) {
-	z.logger.Sugar().Info(msg, toZapFields(args))
+	z.log(zap.InfoLevel, msg, args)
 }
 
 func (z *ZapAdapter) Warn(msg string, args ...any) {
-	z.logger.Sugar().Warn(msg, toZapFields(args))
+	z.log(zap.WarnLevel, msg, args)
 }
 
 func (z *ZapAdapter) Error(msg string, args ...any) {
-	z.logger.Sugar().Error(msg, toZapFields(args))
+	z.log(zap.ErrorLevel, msg, args)
+}
+
+func (z *ZapAdapter) DebugContext(ctx context.Context, msg string, args ...any) {
+	z.log(zap.DebugLevel, msg, append(ContextArgs(ctx), args...))
+}
+
+func (z *ZapAdapter) InfoContext(ctx context.Context, msg string, args ...any) {
+	z.log(zap.InfoLevel, msg, append(ContextArgs(ctx), args...))
+}
+
+func (z *ZapAdapter) WarnContext(ctx context.Context, msg string, args ...any) {
+	z.log(zap.WarnLevel, msg, append(ContextArgs(ctx), args...))
+}
+
+func (z *ZapAdapter) ErrorContext(ctx context.Context, msg string, args ...any) {
+	z.log(zap.ErrorLevel, msg, append(ContextArgs(ctx), args...))
 }
 
+// log uses the unsugared fast path, and replaces the caller zap found (if
+// it was asked to) with the adapter's caller.
+func (z *ZapAdapter) log(level zapcore.Level, msg string, args []any) {
+	ce := z.logger.Check(level, msg)
+	if ce == nil {
+		return
+	}
+
+	if ce.Caller.Defined {
+		if pc := callerPC(); pc != 0 {
+			frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+			ce.Caller = zapcore.EntryCaller{
+				Defined:  true,
+				PC:       frame.PC,
+				File:     frame.File,
+				Line:     frame.Line,
+				Function: frame.Function,
+			}
+		}
+	}
+
+	ce.Write(toZapFields(z.redactor.Args(args))...)
+}
+
+func (z *ZapAdapter) With(args ...any) Logger {
+	return &ZapAdapter{logger: z.logger.With(toZapFields(z.redactor.Args(args))...), redactor: z.redactor}
+}
+
+func (z *ZapAdapter) Named(name string) Logger {
+	return &ZapAdapter{logger: z.logger.Named(name), redactor: z.redactor}
+}
+
+// toZapFields pairs args up the same way slog does: a string is a key for
+// the value that follows it, a slog.Attr stands on its own, and anything
+// else (including a trailing key with no value) is recorded under !BADKEY.
 func toZapFields(args []any) []zap.Field {
-	fields := make([]zap.Field, len(args))
-	for i, arg := range args {
-		fields[i] = zap.Any("", arg)
+	fields := make([]zap.Field, 0, (len(args)+1)/2)
+	for len(args) > 0 {
+		switch key := args[0].(type) {
+		case slog.Attr:
+			fields = appendAttr(fields, key)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				fields = append(fields, zap.String(badKey, key))
+				args = args[1:]
+			} else {
+				fields = append(fields, zap.Any(key, args[1]))
+				args = args[2:]
+			}
+		default:
+			fields = append(fields, zap.Any(badKey, key))
+			args = args[1:]
+		}
 	}
 	return fields
 }
+
+// appendAttr converts attr to zap fields. As with slog, empty attributes are
+// dropped and groups without a key are inlined.
+func appendAttr(fields []zap.Field, attr slog.Attr) []zap.Field {
+	value := attr.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		if attr.Equal(slog.Attr{}) {
+			return fields
+		}
+		return append(fields, zap.Any(attr.Key, value.Any()))
+	}
+
+	var group []zap.Field
+	for _, a := range value.Group() {
+		group = appendAttr(group, a)
+	}
+
+	switch {
+	case len(group) == 0:
+		return fields
+	case attr.Key == "":
+		return append(fields, group...)
+	default:
+		return append(fields, zap.Dict(attr.Key, group...))
+	}
+}
@@ -0,0 +1,71 @@
+package daemon_test
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapAdapterFields(t *testing.T) {
+	tests := []struct {
+		name string
+		args []any
+		want map[string]any
+	}{
+		{"pairs", []any{"a", 1, "b", "x"}, map[string]any{"a": int64(1), "b": "x"}},
+		{"attr", []any{slog.Int("n", 2), "a", 1}, map[string]any{"n": int64(2), "a": int64(1)}},
+		{"trailing key", []any{"a", 1, "dangling"}, map[string]any{"a": int64(1), "!BADKEY": "dangling"}},
+		{"non-string key", []any{42, "a", 1}, map[string]any{"!BADKEY": int64(42), "a": int64(1)}},
+		{"group", []any{slog.Group("g", "x", 1, slog.String("y", "z"))}, map[string]any{"g": map[string]any{"x": int64(1), "y": "z"}}},
+		{"inline group", []any{slog.Group("", slog.Int("y", 2))}, map[string]any{"y": int64(2)}},
+		{"empty attr", []any{slog.Attr{}, "a", 1}, map[string]any{"a": int64(1)}},
+		{"empty group", []any{slog.Group("g"), "a", 1}, map[string]any{"a": int64(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			daemon.NewZapAdapter(zap.New(core)).Info("hi", tt.args...)
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			if got := entries[0].ContextMap(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZapAdapterWithNamed(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	logger := daemon.NewZapAdapter(zap.New(core))
+	ctx := daemon.ContextWithIdentity(context.Background(), daemon.Identity{Name: "ticker"})
+
+	child := logger.Named("a").With("k", "v", "password", "hunter2").Named("b")
+	child.WarnContext(ctx, "hi", "n", 1)
+	logger.Debug("parent")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.LoggerName != "a.b" || e.Level != zap.WarnLevel || e.Message != "hi" {
+		t.Errorf("got %s %q from %q, want warn hi from a.b", e.Level, e.Message, e.LoggerName)
+	}
+	want := map[string]any{"k": "v", "password": daemon.Redacted, "n": int64(1), daemon.DaemonKey: "ticker"}
+	if got := e.ContextMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+
+	if parent := entries[1]; parent.LoggerName != "" || len(parent.Context) != 0 {
+		t.Errorf("child's name or fields leaked into its parent: %q %v", parent.LoggerName, parent.ContextMap())
+	}
+}
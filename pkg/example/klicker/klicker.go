@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/logging/kafkalog"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
@@ -45,8 +46,11 @@ func NewKlicker(options ...Option) (*Klicker, error) {
 }
 
 func (s *Klicker) Setup(ctx context.Context) error {
+	kafkaLogger := kafkalog.NewKgoLogger(s.logger, kgo.LogLevelInfo)
+
 	adm, err := kadm.NewOptClient(
 		kgo.SeedBrokers(s.bootstrapURIs...),
+		kgo.WithLogger(kafkaLogger),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create kafka admin client: %w", err)
@@ -70,6 +74,7 @@ func (s *Klicker) Setup(ctx context.Context) error {
 
 	klient, err := kgo.NewClient(
 		kgo.SeedBrokers(s.bootstrapURIs...),
+		kgo.WithLogger(kafkaLogger),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create kafka client: %w", err)
@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Batching bounds how much the gateway handles in a single transaction.
+// With MaxDuration set, a transaction takes in polls until it holds
+// MaxRecords records or MaxBytes bytes of keys and values, or has been open
+// for MaxDuration, whichever comes first; MaxDuration must stay well within
+// the client's transaction timeout. Without it, a transaction takes in a
+// single poll, of at most MaxRecords records. Zero limits are unbounded.
+type Batching struct {
+	MaxRecords  int
+	MaxBytes    int
+	MaxDuration time.Duration
+}
+
+// batch tracks the transaction being filled, and the partition workers
+// consuming its records. The first of them to fail cancels ctx, so that
+// the rest stop early.
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	began   time.Time
+	records int
+	bytes   int
+	// offsets spans the records handed to each partition's worker.
+	offsets map[topicPartition]offsetRange
+
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+type offsetRange struct {
+	first, last int64
+}
+
+func newBatch(ctx context.Context) *batch {
+	ctx, cancel := context.WithCancel(ctx)
+	return &batch{ctx: ctx, cancel: cancel, offsets: make(map[topicPartition]offsetRange)}
+}
+
+// start registers a run of records handed to a worker.
+func (b *batch) start() {
+	b.wg.Add(1)
+}
+
+// finish records that a run of records has been consumed.
+func (b *batch) finish(err error) {
+	if err != nil {
+		b.fail(err)
+	}
+	b.wg.Done()
+}
+
+// fail records err, unless b has already failed, and cancels ctx so that
+// the workers stop consuming b's records.
+func (b *batch) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err == nil {
+		b.err = err
+		b.cancel()
+	}
+}
+
+// failed reports whether a worker has failed on b.
+func (b *batch) failed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err != nil
+}
+
+// wait waits for the workers to consume everything handed to them, and
+// returns the first error they had.
+func (b *batch) wait() error {
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err
+}
+
+func (b *batch) add(n, size int) {
+	if b.began.IsZero() {
+		b.began = time.Now()
+	}
+	b.records += n
+	b.bytes += size
+}
+
+// waitContext is wait, giving up when ctx is done; done reports whether
+// the workers finished.
+func (b *batch) waitContext(ctx context.Context) (done bool, err error) {
+	finished := make(chan error, 1)
+	go func() {
+		finished <- b.wait()
+	}()
+
+	select {
+	case err := <-finished:
+		return true, err
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+// dispatched records that records, of a single partition, were handed to
+// its worker.
+func (b *batch) dispatched(tp topicPartition, records []*kgo.Record) {
+	span, ok := b.offsets[tp]
+	if !ok {
+		span.first = records[0].Offset
+	}
+	span.last = records[len(records)-1].Offset
+	b.offsets[tp] = span
+}
+
+func (b *batch) open() bool {
+	return !b.began.IsZero()
+}
+
+// full reports whether b should take in no more polls.
+func (p Batching) full(b *batch) bool {
+	switch {
+	case p.MaxDuration <= 0:
+		return true
+	case p.MaxRecords > 0 && b.records >= p.MaxRecords:
+		return true
+	case p.MaxBytes > 0 && b.bytes >= p.MaxBytes:
+		return true
+	default:
+		return !time.Now().Before(p.deadline(b))
+	}
+}
+
+// deadline returns when b must be committed by.
+func (p Batching) deadline(b *batch) time.Time {
+	return b.began.Add(p.MaxDuration)
+}
+
+// limit returns how many more records b can take, or zero for no limit.
+func (p Batching) limit(b *batch) int {
+	if p.MaxRecords <= 0 {
+		return 0
+	}
+	return max(p.MaxRecords-b.records, 1)
+}
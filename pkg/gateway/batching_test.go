@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBatchingFull(t *testing.T) {
+	tests := []struct {
+		name     string
+		batching Batching
+		records  int
+		bytes    int
+		age      time.Duration
+		want     bool
+	}{
+		{"single poll", Batching{MaxRecords: 10}, 1, 1, 0, true},
+		{"room left", Batching{MaxRecords: 10, MaxBytes: 100, MaxDuration: time.Minute}, 9, 99, time.Second, false},
+		{"records", Batching{MaxRecords: 10, MaxDuration: time.Minute}, 10, 1, 0, true},
+		{"bytes", Batching{MaxBytes: 100, MaxDuration: time.Minute}, 1, 100, 0, true},
+		{"duration", Batching{MaxRecords: 10, MaxDuration: time.Second}, 1, 1, time.Second, true},
+		{"unbounded records and bytes", Batching{MaxDuration: time.Minute}, 1 << 20, 1 << 30, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBatch(context.Background())
+			b.add(tt.records, tt.bytes)
+			b.began = b.began.Add(-tt.age)
+
+			if got := tt.batching.full(b); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchingLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		batching Batching
+		records  int
+		want     int
+	}{
+		{"unbounded", Batching{}, 5, 0},
+		{"empty", Batching{MaxRecords: 10}, 0, 10},
+		{"room left", Batching{MaxRecords: 10}, 7, 3},
+		{"full", Batching{MaxRecords: 10}, 10, 1},
+		{"over", Batching{MaxRecords: 10}, 12, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBatch(context.Background())
+			b.records = tt.records
+
+			if got := tt.batching.limit(b); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package gateway
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Headers describing why a record was dead-lettered, and where from.
+const (
+	DeadLetterErrorHeader     = "dlq-error"
+	DeadLetterClassHeader     = "dlq-error-class"
+	DeadLetterTopicHeader     = "dlq-source-topic"
+	DeadLetterPartitionHeader = "dlq-source-partition"
+	DeadLetterOffsetHeader    = "dlq-source-offset"
+	DeadLetterAttemptsHeader  = "dlq-attempts"
+	DeadLetterTimeHeader      = "dlq-timestamp"
+)
+
+// deadLetter returns a copy of record for the dead-letter topic, with
+// headers recording err, the number of attempts made and where the record
+// was first consumed from.
+func (s *Gateway) deadLetter(record *kgo.Record, err error, attempts int) *kgo.Record {
+	topic, partition, offset := origin(record)
+	headers := slices.Clone(record.Headers)
+	headers = append(headers,
+		kgo.RecordHeader{Key: DeadLetterErrorHeader, Value: []byte(err.Error())},
+		kgo.RecordHeader{Key: DeadLetterClassHeader, Value: []byte(daemon.Classify(err).String())},
+		kgo.RecordHeader{Key: DeadLetterTopicHeader, Value: []byte(topic)},
+		kgo.RecordHeader{Key: DeadLetterPartitionHeader, Value: []byte(strconv.FormatInt(int64(partition), 10))},
+		kgo.RecordHeader{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(offset, 10))},
+		kgo.RecordHeader{Key: DeadLetterAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+		kgo.RecordHeader{Key: DeadLetterTimeHeader, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	return &kgo.Record{
+		Topic:   s.deadLetterTopic,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+	}
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestDeadLetter(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		tiers   []time.Duration
+		err     error
+		want    map[string]string
+	}{
+		{"no retries", 0, nil, errors.New("boom"), map[string]string{
+			DeadLetterErrorHeader:    "handling record: boom",
+			DeadLetterClassHeader:    daemon.ClassUnknown.String(),
+			DeadLetterAttemptsHeader: "1",
+		}},
+		{"retried in place", 2, nil, daemon.Transient(errors.New("flaky")), map[string]string{
+			DeadLetterErrorHeader:    "handling record: flaky",
+			DeadLetterClassHeader:    daemon.ClassTransient.String(),
+			DeadLetterAttemptsHeader: "3",
+		}},
+		{"permanent skips the tiers", 2, []time.Duration{time.Second}, daemon.Permanent(errors.New("broken")), map[string]string{
+			DeadLetterErrorHeader:    "handling record: broken",
+			DeadLetterClassHeader:    daemon.ClassPermanent.String(),
+			DeadLetterAttemptsHeader: "1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HandlerFunc(func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+				return nil, tt.err
+			})
+			gw, session := newTestGateway(t, handler,
+				WithDeadLetterTopic("dlq"),
+				WithRetryTiers(tt.tiers...),
+				WithRecordRetry(daemon.RetryPolicy{MaxRetries: tt.retries}),
+			)
+
+			record := &kgo.Record{
+				Topic:     "topic",
+				Partition: 3,
+				Offset:    42,
+				Key:       []byte("key"),
+				Value:     []byte("value"),
+				Headers:   []kgo.RecordHeader{{Key: "trace", Value: []byte("abc")}},
+			}
+			if err := gw.consume(context.Background(), record); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(session.produced) != 1 {
+				t.Fatalf("got %d records produced, want 1", len(session.produced))
+			}
+			dead := session.produced[0]
+			if dead.Topic != "dlq" || string(dead.Key) != "key" || string(dead.Value) != "value" {
+				t.Errorf("got %s %q=%q, want the record on dlq", dead.Topic, dead.Key, dead.Value)
+			}
+			want := map[string]string{
+				"trace":                   "abc",
+				DeadLetterTopicHeader:     "topic",
+				DeadLetterPartitionHeader: "3",
+				DeadLetterOffsetHeader:    "42",
+			}
+			for key, value := range tt.want {
+				want[key] = value
+			}
+			for key, value := range want {
+				if got, _ := header(dead, key); got != value {
+					t.Errorf("got header %s %q, want %q", key, got, value)
+				}
+			}
+			if at, _ := header(dead, DeadLetterTimeHeader); at == "" {
+				t.Error("got no dead-letter timestamp")
+			}
+		})
+	}
+}
+
+func TestDeadLetterAfterRetryTiers(t *testing.T) {
+	gw, _ := newTestGateway(t, HandlerFunc(func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+		return nil, nil
+	}), WithDeadLetterTopic("dlq"), WithRetryTiers(time.Second))
+
+	record := &kgo.Record{Topic: "topic", Partition: 3, Offset: 42}
+	retry, _ := gw.nextRetry(record, errors.New("boom"), 2)
+	retry.Partition, retry.Offset = 0, 7
+
+	dead := gw.deadLetter(retry, errors.New("boom"), 3)
+	want := map[string]string{
+		DeadLetterTopicHeader:     "topic",
+		DeadLetterPartitionHeader: "3",
+		DeadLetterOffsetHeader:    "42",
+		DeadLetterAttemptsHeader:  "3",
+		// The retry headers are kept, for the history they tell.
+		RetryTierHeader: "0",
+	}
+	for key, value := range want {
+		if got, _ := header(dead, key); got != value {
+			t.Errorf("got header %s %q, want %q", key, got, value)
+		}
+	}
+}
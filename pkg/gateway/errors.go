@@ -0,0 +1,64 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// rebalanceErrors are not retriable as far as the broker is concerned, but
+// only mean the group moved on, or the transaction must be aborted, while
+// the gateway was working; the gateway recovers by handling the records
+// again.
+var rebalanceErrors = []error{
+	kerr.IllegalGeneration,
+	kerr.UnknownMemberID,
+	kerr.RebalanceInProgress,
+	kerr.StaleMemberEpoch,
+	kerr.ConcurrentTransactions,
+	kerr.TransactionAbortable,
+}
+
+// classifyKafkaError marks err as transient if the broker says it can be
+// retried, or if it comes of a rebalance, and as permanent otherwise.
+// Errors that are already classified, and errors that did not come from
+// the broker, are left alone.
+func classifyKafkaError(err error) error {
+	if err == nil || daemon.Classify(err) != daemon.ClassUnknown {
+		return err
+	}
+
+	var kafkaErr *kerr.Error
+	if !errors.As(err, &kafkaErr) {
+		return err
+	}
+
+	if kerr.IsRetriable(err) || slices.ContainsFunc(rebalanceErrors, func(target error) bool {
+		return errors.Is(err, target)
+	}) {
+		return daemon.Transient(err)
+	}
+	return daemon.Permanent(err)
+}
+
+// classifyFetchErrors joins the fetch errors into a single error, which is
+// permanent only if every one of them is.
+func classifyFetchErrors(errs []kgo.FetchError) error {
+	joined := make([]error, 0, len(errs))
+	permanent := true
+	for _, fe := range errs {
+		err := classifyKafkaError(fmt.Errorf("fetching %s[%d]: %w", fe.Topic, fe.Partition, fe.Err))
+		permanent = permanent && daemon.IsPermanent(err)
+		joined = append(joined, err)
+	}
+
+	err := fmt.Errorf("fetch errors: %w", errors.Join(joined...))
+	if permanent {
+		return daemon.Permanent(err)
+	}
+	return daemon.Transient(err)
+}
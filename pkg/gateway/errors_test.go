@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestClassifyKafkaError(t *testing.T) {
+	plain := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want daemon.ErrorClass
+	}{
+		{"nil", nil, daemon.ClassUnknown},
+		{"not from the broker", plain, daemon.ClassUnknown},
+		{"retriable", kerr.NotLeaderForPartition, daemon.ClassTransient},
+		{"wrapped retriable", fmt.Errorf("producing: %w", kerr.RequestTimedOut), daemon.ClassTransient},
+		{"rebalance", kerr.RebalanceInProgress, daemon.ClassTransient},
+		{"fenced epoch", kerr.IllegalGeneration, daemon.ClassTransient},
+		{"abortable", kerr.TransactionAbortable, daemon.ClassTransient},
+		{"fatal", kerr.TopicAuthorizationFailed, daemon.ClassPermanent},
+		{"fenced producer", kerr.ProducerFenced, daemon.ClassPermanent},
+		{"already permanent", daemon.Permanent(kerr.NotLeaderForPartition), daemon.ClassPermanent},
+		{"already transient", daemon.Transient(kerr.TopicAuthorizationFailed), daemon.ClassTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyKafkaError(tt.err)
+			if got := daemon.Classify(err); got != tt.want {
+				t.Errorf("got class %v, want %v", got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, which does not wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClassifyFetchErrors(t *testing.T) {
+	fetchError := func(partition int32, err error) kgo.FetchError {
+		return kgo.FetchError{Topic: "topic", Partition: partition, Err: err}
+	}
+
+	tests := []struct {
+		name string
+		errs []kgo.FetchError
+		want daemon.ErrorClass
+	}{
+		{"retriable", []kgo.FetchError{fetchError(0, kerr.NotLeaderForPartition)}, daemon.ClassTransient},
+		{"fatal", []kgo.FetchError{fetchError(0, kerr.TopicAuthorizationFailed)}, daemon.ClassPermanent},
+		{"all fatal", []kgo.FetchError{
+			fetchError(0, kerr.TopicAuthorizationFailed),
+			fetchError(1, kerr.UnsupportedVersion),
+		}, daemon.ClassPermanent},
+		{"some fatal", []kgo.FetchError{
+			fetchError(0, kerr.TopicAuthorizationFailed),
+			fetchError(1, kerr.NotLeaderForPartition),
+		}, daemon.ClassTransient},
+		{"not from the broker", []kgo.FetchError{fetchError(0, errors.New("boom"))}, daemon.ClassTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyFetchErrors(tt.errs)
+			if got := daemon.Classify(err); got != tt.want {
+				t.Errorf("got class %v, want %v", got, tt.want)
+			}
+			for _, fe := range tt.errs {
+				if !errors.Is(err, fe.Err) {
+					t.Errorf("got %v, which does not wrap %v", err, fe.Err)
+				}
+			}
+		})
+	}
+}
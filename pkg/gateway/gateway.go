@@ -2,10 +2,16 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/logging/kafkalog"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
@@ -13,23 +19,99 @@ type Logger interface {
 	daemon.Logger
 }
 
+// transactSession is the part of kgo.GroupTransactSession the gateway
+// uses.
+type transactSession interface {
+	Begin() error
+	End(ctx context.Context, commit kgo.TransactionEndTry) (bool, error)
+	PollFetches(ctx context.Context) kgo.Fetches
+	PollRecords(ctx context.Context, maxPollRecords int) kgo.Fetches
+	ProduceSync(ctx context.Context, rs ...*kgo.Record) kgo.ProduceResults
+	Client() *kgo.Client
+	Close()
+}
+
+var _ transactSession = (*kgo.GroupTransactSession)(nil)
+
 type Gateway struct {
 	brokerURIs []string
 	topic      string
-	client     *kgo.Client
+	session    transactSession
 	closers    []func() error
 	logger     Logger
 	name       string
 	handler    Handler
+	middleware []Middleware
+
+	// listener is told of assignment changes, if the handler wants them.
+	listener AssignmentListener
+
+	correlationHeader string
+	kafkaLogLevel     kgo.LogLevel
+
+	// transactionalID identifies the gateway's producer to the brokers, so
+	// that a zombie instance with the same ID is fenced off.
+	transactionalID string
+
+	// pollRetry applies to failed polls, recordRetry to each record.
+	pollRetry   daemon.RetryPolicy
+	recordRetry daemon.RetryPolicy
+
+	// retryTiers hold records the handler fails on for increasing delays,
+	// without blocking the partitions they came from. held has the tier
+	// partitions paused until their next record is due.
+	retryTiers []retryTier
+	heldMu     sync.Mutex
+	held       map[topicPartition]time.Time
+
+	// workers consume the records of each assigned partition, queueing
+	// up to partitionBuffer polls' worth.
+	workersMu       sync.Mutex
+	workers         map[topicPartition]*partitionWorker
+	partitionBuffer int
+	// keyParallelism is how many of a partition's records, with different
+	// keys, may be handled at once.
+	keyParallelism int
+	// revoked is set when partitions are revoked, until the session's
+	// transaction is next ended; the session passes on no further
+	// revocations in the meantime.
+	revoked atomic.Bool
+
+	// batching bounds each transaction. isolate counts the records left
+	// to handle one per transaction after a batch failed.
+	batching Batching
+	isolate  int
+	// current is the batch in flight, which Shutdown finishes.
+	batchMu sync.Mutex
+	current *batch
+
+	// deadLetterTopic receives records the handler gives up on.
+	deadLetterTopic string
+
+	// recordLogger logs per-record failures, which can be sampled
+	// separately so that a poison batch does not flood the logs.
+	recordLogger   Logger
+	recordSampling *daemon.Sampling
 }
 
 func NewGateway(options ...Option) (*Gateway, error) {
 	gw := &Gateway{
 		brokerURIs: nil,
 		topic:      "",
-		client:     nil,
+		session:    nil,
 		closers:    nil,
 		name:       "",
+
+		correlationHeader: DefaultCorrelationHeader,
+		kafkaLogLevel:     kgo.LogLevelInfo,
+
+		pollRetry:   daemon.RetryPolicy{MaxRetries: 4, Delay: 1 * time.Second},
+		recordRetry: daemon.RetryPolicy{MaxRetries: 0},
+		held:        make(map[topicPartition]time.Time),
+
+		workers:         make(map[topicPartition]*partitionWorker),
+		partitionBuffer: DefaultPartitionBuffer,
+		keyParallelism:  1,
 	}
 
 	for _, opt := range options {
@@ -42,29 +124,71 @@ func NewGateway(options ...Option) (*Gateway, error) {
 		WithName(fmt.Sprintf("daemonic.gateway.%p", gw))(gw)
 	}
 
+	if gw.logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
 	if gw.topic == "" {
 		return nil, fmt.Errorf("topic is not configured")
 	}
 
+	for i := range gw.retryTiers {
+		gw.retryTiers[i].topic = RetryTopic(gw.topic, gw.retryTiers[i].delay)
+	}
+
+	if gw.handler == nil {
+		return nil, fmt.Errorf("handler is required")
+	}
+	gw.listener, _ = gw.handler.(AssignmentListener)
+	gw.handler = Chain(gw.middleware...)(gw.handler)
+
+	// Name the logger after the gateway, so that its level can be set on
+	// its own.
+	gw.logger = gw.logger.Named(gw.name)
+	gw.recordLogger = gw.logger
+
 	return gw, nil
 }
 
 func (s *Gateway) Setup(ctx context.Context) error {
-	if s.client == nil {
-		klient, err := kgo.NewClient(
+	if _, ok := s.recordLogger.(*daemon.SampledLogger); s.recordSampling != nil && !ok {
+		sampled, err := daemon.NewSampledLogger(s.logger, *s.recordSampling)
+		if err != nil {
+			return fmt.Errorf("failed to sample record logs: %w", err)
+		}
+
+		s.recordLogger = sampled
+		s.closers = append(s.closers, func() error {
+			sampled.Close()
+			return nil
+		})
+	}
+
+	if s.session == nil {
+		if s.transactionalID == "" {
+			s.transactionalID = defaultTransactionalID(ctx, s.name)
+		}
+
+		session, err := kgo.NewGroupTransactSession(
 			kgo.SeedBrokers(s.brokerURIs...),
 			kgo.ConsumerGroup(s.name),
-			kgo.ConsumeTopics(s.topic),
-			kgo.FetchIsolationLevel(kgo.ReadCommitted),
+			kgo.ConsumeTopics(s.topics()...),
+			kgo.TransactionalID(s.transactionalID),
+			kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+			kgo.RequireStableFetchOffsets(),
+			kgo.OnPartitionsAssigned(s.onAssigned),
+			kgo.OnPartitionsRevoked(s.onRevoked),
+			kgo.OnPartitionsLost(s.onRevoked),
+			kgo.WithLogger(kafkalog.NewKgoLogger(s.logger, s.kafkaLogLevel)),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create kafka client: %w", err)
 		}
 
-		s.client = klient
+		s.session = session
 		s.closers = append(s.closers, func() error {
 			s.logger.Info("closing kafka client")
-			s.client.Close()
+			s.session.Close()
 			s.logger.Info("kafka client closed")
 			return nil
 		})
@@ -73,71 +197,372 @@ func (s *Gateway) Setup(ctx context.Context) error {
 	return nil
 }
 
+// defaultTransactionalID names the gateway's transactions after the group,
+// the host and process it runs in, and the replica it is within a Pool, so
+// that instances sharing the group do not fence one another off.
+func defaultTransactionalID(ctx context.Context, group string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	id := fmt.Sprintf("%s-%s-%d", group, host, os.Getpid())
+	if identity, ok := daemon.IdentityFromContext(ctx); ok && identity.Pooled {
+		id = fmt.Sprintf("%s-%d", id, identity.Index)
+	}
+	return id
+}
+
 func (s *Gateway) Run(ctx context.Context) error {
-	errorCount := 0
-	maxErrorCount := 5
+	var delay time.Duration
+	for retries := 0; ; {
+		if ctx.Err() != nil {
+			return nil
+		}
 
-	for {
-		select {
-		case <-ctx.Done():
+		err := s.handle(ctx)
+		if err == nil {
+			retries, delay = 0, 0
+			continue
+		}
+
+		s.logger.ErrorContext(ctx, "handling errors", "errors", err, "class", daemon.Classify(err))
+		if daemon.IsPermanent(err) {
+			return fmt.Errorf("permanent error: %w", err)
+		}
+
+		if !s.pollRetry.Retryable(err, retries) {
+			return fmt.Errorf("giving up after %d retries: %w", retries, err)
+		}
+
+		delay = s.pollRetry.Wait(err, retries, delay)
+		retries++
+		if !daemon.Sleep(ctx, delay) {
 			return nil
-		default:
-			// NOTE: Add backoff here.
-			err := s.handle(ctx)
-			if err == nil {
-				errorCount = 0
-			} else {
-				s.logger.Error("handling errors", "errors", err)
-				errorCount++
-				if errorCount >= maxErrorCount {
-					return fmt.Errorf("exceeded maximum error count of %d", maxErrorCount)
-				}
-				time.Sleep(1 * time.Second)
-			}
 		}
 	}
 }
 
+// handle handles a batch of polled records in a single transaction, along
+// with the consumed offsets. Each partition's records are consumed, in
+// order, by a worker of its own. If a record cannot be handled, the
+// transaction is aborted, and the records are consumed again; to keep the
+// failure from holding up the rest, they are then handled one per
+// transaction until the batch has been got through.
+//
+// When ctx is cancelled, handle stops fetching and returns without waiting
+// on the workers, leaving the batch in flight for Shutdown to finish.
 func (s *Gateway) handle(ctx context.Context) error {
-	fetches := s.client.PollFetches(ctx)
-	if errs := fetches.Errors(); len(errs) > 0 {
-		return fmt.Errorf("fetch errors: %v", errs)
-	}
-
-	var err error
-	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
-		p.EachRecord(func(record *kgo.Record) {
-			err := s.client.BeginTransaction(ctx)
-			if err != nil {
-				s.logger.Warn("beginning transaction", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
-				_ = s.client.AbortTransaction(ctx)
-			}
+	b := newBatch(context.WithoutCancel(ctx))
 
-			records, err := s.handler.Handle(ctx, record)
-			if err != nil {
-				s.logger.Warn("handling record", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
-				_ = s.client.AbortTransaction(ctx)
-			}
+	s.batchMu.Lock()
+	s.current = b
+	s.batchMu.Unlock()
+	inFlight := false
+	defer func() {
+		if inFlight {
+			return
+		}
+		b.cancel()
+		s.batchMu.Lock()
+		s.current = nil
+		s.batchMu.Unlock()
+	}()
 
-			if len(records) > 0 {
-				err = s.client.ProduceSync(ctx, records...).FirstErr()
-				if err != nil {
-					s.logger.Warn("producing records", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
-					_ = s.client.AbortTransaction(ctx)
+	for {
+		fetches, err := s.poll(ctx, b)
+		if err != nil {
+			if b.open() {
+				// Abort the unfinished batch, so that its records are
+				// consumed again. Failing it stops its workers early; if
+				// stopping cuts the wait short, Shutdown aborts it.
+				b.fail(err)
+				if done, _ := b.waitContext(ctx); !done {
+					inFlight = true
+					return nil
 				}
+				err = errors.Join(err, s.end(ctx, kgo.TryAbort))
 			}
+			return err
+		}
 
-			err := s.client.CommitTransaction(ctx)
-			if err != nil {
-				s.logger.Warn("committing transaction", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
-				_ = s.client.AbortTransaction(ctx)
+		if n := fetches.NumRecords(); n > 0 {
+			if !b.open() {
+				if err := s.session.Begin(); err != nil {
+					return classifyKafkaError(fmt.Errorf("beginning transaction: %w", err))
+				}
 			}
-		})
+			b.add(n, fetchedBytes(fetches))
+			if s.holdNotDue(fetches) {
+				b.fail(errHeld)
+			} else {
+				s.dispatch(b, fetches)
+			}
+		}
+
+		if ctx.Err() != nil {
+			inFlight = b.open()
+			return nil
+		}
+		if !b.open() {
+			return s.endRevoked(ctx)
+		}
+		if b.failed() || s.isolate > 0 || s.batching.full(b) {
+			break
+		}
+	}
+
+	done, err := b.waitContext(ctx)
+	if !done {
+		inFlight = true
+		return nil
+	}
+	if err != nil {
+		if errors.Is(err, errHeld) {
+			s.logger.DebugContext(ctx, "aborting batch to hold a record not yet due; its records will be consumed again", "records", b.records)
+			return s.end(ctx, kgo.TryAbort)
+		}
+		if b.records > 1 && s.isolate == 0 {
+			s.logger.WarnContext(ctx, "batch failed, handling its records one at a time", "records", b.records, "error", err)
+			s.isolate = b.records
+		}
+		return errors.Join(err, s.end(ctx, kgo.TryAbort))
+	}
+
+	if err := s.end(ctx, kgo.TryCommit); err != nil {
+		return err
+	}
+	s.isolate = max(s.isolate-b.records, 0)
+	return nil
+}
+
+// poll fetches the next records for b: a single record while isolating a
+// failure, and otherwise no more than the batch has room for. It waits no
+// longer than the batch may stay open, or than the first held partition
+// takes to come due.
+func (s *Gateway) poll(ctx context.Context, b *batch) (kgo.Fetches, error) {
+	s.resumeDue()
+
+	pollCtx, cancel := s.pollContext(ctx)
+	defer cancel()
+	if b.open() {
+		var cancelBatch context.CancelFunc
+		pollCtx, cancelBatch = context.WithDeadline(pollCtx, s.batching.deadline(b))
+		defer cancelBatch()
+	}
+
+	limit := s.batching.limit(b)
+	if s.isolate > 0 {
+		limit = 1
+	}
+
+	var fetches kgo.Fetches
+	if limit > 0 {
+		fetches = s.session.PollRecords(pollCtx, limit)
+	} else {
+		fetches = s.session.PollFetches(pollCtx)
+	}
+	if ctx.Err() != nil {
+		// Stopping: the records polled are still handled, as their offsets
+		// are committed with the batch.
+		return fetches, nil
+	}
+
+	if fetchErrs := fetchErrors(fetches); len(fetchErrs) > 0 {
+		return nil, classifyFetchErrors(fetchErrs)
+	}
+	return fetches, nil
+}
+
+func fetchedBytes(fetches kgo.Fetches) int {
+	size := 0
+	fetches.EachRecord(func(r *kgo.Record) {
+		size += len(r.Key) + len(r.Value)
 	})
+	return size
+}
+
+// end ends the current transaction. The session aborts it, rather than
+// committing, if partitions were revoked while it was open; the records
+// are then consumed again by whoever owns them now.
+func (s *Gateway) end(ctx context.Context, try kgo.TransactionEndTry) error {
+	s.revoked.Store(false)
+	committed, err := s.session.End(ctx, try)
+	if err != nil {
+		return classifyKafkaError(fmt.Errorf("ending transaction: %w", err))
+	}
+
+	if try == kgo.TryCommit && !committed {
+		s.logger.WarnContext(ctx, "transaction aborted after a rebalance; its records will be consumed again")
+	}
 
 	return nil
 }
 
-func (s *Gateway) Shutdown(ctx context.Context) error {
+// endRevoked ends the session's transaction, though none is open, if
+// partitions have been revoked since it last ended, so that an idle gateway
+// goes on being told of rebalances. With nothing in flight, the session
+// rewinding to the committed offsets loses nothing.
+func (s *Gateway) endRevoked(ctx context.Context) error {
+	if !s.revoked.Load() {
+		return nil
+	}
+	return s.end(ctx, kgo.TryAbort)
+}
+
+// fetchErrors returns the errors in fetches, leaving out the poll being cut
+// short for a held record coming due.
+func fetchErrors(fetches kgo.Fetches) []kgo.FetchError {
+	return slices.DeleteFunc(fetches.Errors(), func(fe kgo.FetchError) bool {
+		return errors.Is(fe.Err, context.DeadlineExceeded)
+	})
+}
+
+// consume processes record, returning only the errors that should abort
+// the transaction.
+func (s *Gateway) consume(ctx context.Context, record *kgo.Record) error {
+	err := s.processRecord(ctx, record)
+	if err == nil {
+		return nil
+	}
+
+	if daemon.IsPermanent(err) {
+		// Retrying will not help; drop the record and move on.
+		s.recordLogger.WarnContext(s.recordContext(ctx, record), "skipping record", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
+		return nil
+	}
+
+	return err
+}
+
+// processRecord handles record, retrying it in place according to the
+// record retry policy, and produces the result in the current transaction.
+// Once the retries are used up, a record the handler fails on is sent to
+// the next retry tier, or failing that the dead-letter topic, in place of
+// what it would have produced.
+func (s *Gateway) processRecord(ctx context.Context, record *kgo.Record) error {
+	ctx = s.recordContext(ctx, record)
+
+	var delay time.Duration
+	for retries := 0; ; retries++ {
+		records, err := s.handler.Handle(ctx, record)
+		if err == nil {
+			return s.produce(ctx, record, records)
+		}
+
+		err = fmt.Errorf("handling record: %w", err)
+		if !s.recordRetry.Retryable(err, retries) {
+			return s.giveUp(ctx, record, err, retries)
+		}
+
+		delay = s.recordRetry.Wait(err, retries, delay)
+		s.recordLogger.InfoContext(ctx, "retrying record", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "retry", retries+1, "delay", delay, "error", err)
+		if !daemon.Sleep(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// giveUp sends record to the next retry tier or the dead-letter topic,
+// returning err if there is neither.
+func (s *Gateway) giveUp(ctx context.Context, record *kgo.Record, err error, retries int) error {
+	if retry, ok := s.scheduleRetry(ctx, record, err, retries); ok {
+		return s.produce(ctx, record, []*kgo.Record{retry})
+	}
+
+	if s.deadLetterTopic != "" {
+		s.recordLogger.WarnContext(ctx, "dead-lettering record", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "dead_letter_topic", s.deadLetterTopic, "attempts", attempts(record, retries), "error", err)
+		return s.produce(ctx, record, []*kgo.Record{s.deadLetter(record, err, attempts(record, retries))})
+	}
+
+	s.recordLogger.WarnContext(ctx, "handling record", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
+	return err
+}
+
+// produce produces records, on behalf of record, in the current
+// transaction.
+func (s *Gateway) produce(ctx context.Context, record *kgo.Record, records []*kgo.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	s.propagateCorrelation(ctx, records)
+	if err := s.session.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		s.recordLogger.WarnContext(ctx, "producing records", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "error", err)
+		return classifyKafkaError(fmt.Errorf("producing records: %w", err))
+	}
+
 	return nil
 }
+
+// Shutdown finishes the batch Run was handling when it was stopped and
+// commits it, then leaves the consumer group and closes the client. If ctx
+// ends first, the batch is abandoned, and the records left unfinished are
+// reported; the brokers abort its transaction once it times out, or the
+// gateway starts again, so they are consumed again.
+func (s *Gateway) Shutdown(ctx context.Context) error {
+	s.logger.InfoContext(ctx, "shutting down gateway")
+
+	err := s.drain(ctx)
+	if stopErr := s.stopWorkers(ctx, nil); stopErr != nil {
+		err = errors.Join(err, stopErr)
+	}
+	if s.session != nil {
+		if leaveErr := s.session.Client().LeaveGroupContext(ctx); leaveErr != nil {
+			err = errors.Join(err, fmt.Errorf("leaving consumer group: %w", leaveErr))
+		}
+	}
+	for _, closer := range s.closers {
+		err = errors.Join(err, closer())
+	}
+
+	s.logger.InfoContext(ctx, "gateway is down")
+	return err
+}
+
+// drain waits for the workers to finish the batch in flight, if there is
+// one, and ends its transaction.
+func (s *Gateway) drain(ctx context.Context) error {
+	s.batchMu.Lock()
+	b := s.current
+	s.current = nil
+	s.batchMu.Unlock()
+	if b == nil {
+		return nil
+	}
+	defer b.cancel()
+
+	s.logger.InfoContext(ctx, "finishing batch in flight", "records", b.records)
+	done, err := b.waitContext(ctx)
+	if !done {
+		unfinished := s.unfinished(b)
+		b.fail(ctx.Err())
+		return fmt.Errorf("%d partitions left with unfinished records: %w", unfinished, ctx.Err())
+	}
+	if err != nil {
+		s.logger.WarnContext(ctx, "aborting batch in flight; its records will be consumed again", "records", b.records, "error", err)
+		return s.end(ctx, kgo.TryAbort)
+	}
+	return s.end(ctx, kgo.TryCommit)
+}
+
+// unfinished logs the records of b its workers have not yet handled, and
+// returns how many partitions they are spread over.
+func (s *Gateway) unfinished(b *batch) int {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	partitions := 0
+	for tp, span := range b.offsets {
+		from := span.first
+		if w, ok := s.workers[tp]; ok {
+			from = max(from, w.handled.Load()+1)
+		}
+		if from > span.last {
+			continue
+		}
+		partitions++
+		s.logger.Warn("records left unfinished", "topic", tp.topic, "partition", tp.partition, "from", from, "to", span.last)
+	}
+	return partitions
+}
@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/adamstrickland/daemonic/pkg/daemon/daemontest"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeSession stands in for kgo.GroupTransactSession, serving the polls
+// queued on it, then nothing. Like the session, it passes on no revocation
+// once partitions have been revoked, until End is called.
+type fakeSession struct {
+	client *kgo.Client
+
+	mu       sync.Mutex
+	revoked  bool
+	assigned map[string][]int32
+	polls    []kgo.Fetches
+	// limits records the limit of each poll, or zero for PollFetches.
+	limits   []int
+	produced []*kgo.Record
+	ends     []kgo.TransactionEndTry
+}
+
+func newFakeSession(t *testing.T) *fakeSession {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	return &fakeSession{client: client}
+}
+
+// rebalance revokes every partition and assigns assigned, as an eager
+// balancer does.
+func (f *fakeSession) rebalance(ctx context.Context, gw *Gateway, assigned map[string][]int32) {
+	f.mu.Lock()
+	skip := f.revoked
+	f.revoked = true
+	revoked := f.assigned
+	f.assigned = assigned
+	f.mu.Unlock()
+
+	if !skip {
+		gw.onRevoked(ctx, f.client, revoked)
+	}
+	gw.onAssigned(ctx, f.client, assigned)
+}
+
+func (f *fakeSession) Begin() error { return nil }
+
+func (f *fakeSession) End(_ context.Context, commit kgo.TransactionEndTry) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ends = append(f.ends, commit)
+	wasRevoked := f.revoked
+	f.revoked = false
+	return bool(commit) && !wasRevoked, nil
+}
+
+func (f *fakeSession) PollFetches(ctx context.Context) kgo.Fetches {
+	return f.PollRecords(ctx, 0)
+}
+
+func (f *fakeSession) PollRecords(_ context.Context, limit int) kgo.Fetches {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.limits = append(f.limits, limit)
+	if len(f.polls) == 0 {
+		return nil
+	}
+	fetches := f.polls[0]
+	f.polls = f.polls[1:]
+	return fetches
+}
+
+// queue serves a poll of the given offsets of a partition.
+func (f *fakeSession) queue(topic string, partition int32, offsets ...int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var records []*kgo.Record
+	for _, offset := range offsets {
+		records = append(records, &kgo.Record{
+			Topic:     topic,
+			Partition: partition,
+			Offset:    offset,
+			Value:     []byte(strconv.FormatInt(offset, 10)),
+		})
+	}
+	f.polls = append(f.polls, kgo.Fetches{{Topics: []kgo.FetchTopic{{
+		Topic:      topic,
+		Partitions: []kgo.FetchPartition{{Partition: partition, Records: records}},
+	}}}})
+}
+
+func (f *fakeSession) ProduceSync(_ context.Context, rs ...*kgo.Record) kgo.ProduceResults {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.produced = append(f.produced, rs...)
+	results := make(kgo.ProduceResults, 0, len(rs))
+	for _, r := range rs {
+		results = append(results, kgo.ProduceResult{Record: r})
+	}
+	return results
+}
+
+func (f *fakeSession) Client() *kgo.Client { return f.client }
+
+func (f *fakeSession) Close() {}
+
+func newTestGateway(t *testing.T, handler Handler, options ...Option) (*Gateway, *fakeSession) {
+	t.Helper()
+
+	options = append([]Option{
+		WithLogger(daemontest.NewLogger(t)),
+		ConsumingFromTopic("topic"),
+		WithHandler(handler),
+	}, options...)
+	gw, err := NewGateway(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := newFakeSession(t)
+	gw.session = session
+	t.Cleanup(func() { gw.stopWorkers(context.Background(), nil) })
+
+	return gw, session
+}
+
+func TestDefaultTransactionalID(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance := fmt.Sprintf("group-%s-%d", host, os.Getpid())
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"no identity", context.Background(), instance},
+		{"outside a pool", daemon.ContextWithIdentity(context.Background(), daemon.Identity{Name: "gw"}), instance},
+		{"replica 0", daemon.ContextWithIdentity(context.Background(), daemon.Identity{Name: "gw", Pooled: true}), instance + "-0"},
+		{"replica 2", daemon.ContextWithIdentity(context.Background(), daemon.Identity{Name: "gw", Index: 2, Pooled: true}), instance + "-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultTransactionalID(tt.ctx, "group"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayIsolatesFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	failed := false
+	handler := HandlerFunc(func(_ context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+		if record.Offset == 1 && !failed {
+			failed = true
+			return nil, errors.New("boom")
+		}
+		return []*kgo.Record{{Topic: "out", Value: record.Value}}, nil
+	})
+	gw, session := newTestGateway(t, handler)
+	session.rebalance(ctx, gw, map[string][]int32{"topic": {0}})
+
+	// The batch fails, and is aborted; the records are then polled, and
+	// committed, one at a time, before batching resumes.
+	session.queue("topic", 0, 0, 1, 2)
+	session.queue("topic", 0, 0)
+	session.queue("topic", 0, 1)
+	session.queue("topic", 0, 2)
+	session.queue("topic", 0, 3, 4)
+
+	if err := gw.handle(ctx); err == nil {
+		t.Fatal("got no error from the failed batch")
+	}
+	for range 4 {
+		if err := gw.handle(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []int{0, 1, 1, 1, 0}; !slices.Equal(session.limits, want) {
+		t.Errorf("got poll limits %v, want %v", session.limits, want)
+	}
+	if want := []kgo.TransactionEndTry{kgo.TryAbort, kgo.TryCommit, kgo.TryCommit, kgo.TryCommit, kgo.TryCommit}; !slices.Equal(session.ends, want) {
+		t.Errorf("got transaction ends %v, want %v", session.ends, want)
+	}
+	var values []string
+	for _, r := range session.produced {
+		values = append(values, string(r.Value))
+	}
+	// The records of the aborted batch produced before it failed are
+	// produced again, in the transactions that commit.
+	if want := []string{"0", "1", "2", "3", "4"}; !slices.Equal(values[len(values)-5:], want) {
+		t.Errorf("got produced %q, want them to end with %q", values, want)
+	}
+}
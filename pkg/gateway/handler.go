@@ -1,7 +1,39 @@
 package gateway
 
-import "github.com/twmb/franz-go/pkg/kgo"
+import (
+	"context"
 
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Handler handles a single consumed record, returning the records to
+// produce in its place. ctx carries the record's correlation ID and trace,
+// and is cancelled if the record's partition is revoked, or the gateway's
+// shutdown deadline passes, before the record is handled. Records of
+// different partitions are handled concurrently, so Handle must be safe to
+// call from several goroutines; records of the same partition are handled
+// in order.
 type Handler interface {
-	Handle(*kgo.Record) ([]*kgo.Record, error)
+	Handle(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error)
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error)
+
+func (f HandlerFunc) Handle(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+	return f(ctx, record)
+}
+
+// Middleware wraps a Handler with behaviour common to every handler.
+type Middleware func(Handler) Handler
+
+// Chain composes middleware into one. The first is outermost, so it sees
+// the record first and the result last.
+func Chain(middleware ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			h = middleware[i](h)
+		}
+		return h
+	}
 }
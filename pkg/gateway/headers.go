@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	// DefaultCorrelationHeader is the record header the gateway reads the
+	// correlation ID from, unless configured otherwise.
+	DefaultCorrelationHeader = "correlation-id"
+	// TraceParentHeader is the W3C trace context header.
+	TraceParentHeader = "traceparent"
+)
+
+func header(record *kgo.Record, key string) (string, bool) {
+	for _, h := range record.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// recordContext returns a copy of ctx carrying the correlation ID and trace
+// of record, so that everything logged while handling it can be tied back
+// to it.
+func (s *Gateway) recordContext(ctx context.Context, record *kgo.Record) context.Context {
+	if id, ok := header(record, s.correlationHeader); ok {
+		ctx = daemon.ContextWithCorrelationID(ctx, id)
+	}
+
+	if tp, ok := header(record, TraceParentHeader); ok {
+		// version-traceid-spanid-flags
+		if parts := strings.Split(tp, "-"); len(parts) == 4 {
+			ctx = daemon.ContextWithTrace(ctx, daemon.Trace{TraceID: parts[1], SpanID: parts[2]})
+		}
+	}
+
+	return ctx
+}
+
+// propagateCorrelation copies the correlation ID from ctx onto any of
+// records that do not already carry one.
+func (s *Gateway) propagateCorrelation(ctx context.Context, records []*kgo.Record) {
+	id, ok := daemon.CorrelationIDFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	for _, r := range records {
+		if _, exists := header(r, s.correlationHeader); !exists {
+			r.Headers = append(r.Headers, kgo.RecordHeader{Key: s.correlationHeader, Value: []byte(id)})
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// offsetTracker tracks a run of a partition's records handled out of
+// order, advancing handled to the offset up to which every one has been.
+type offsetTracker struct {
+	mu      sync.Mutex
+	records []*kgo.Record
+	done    []bool
+	next    int
+	handled *atomic.Int64
+}
+
+func newOffsetTracker(records []*kgo.Record, handled *atomic.Int64) *offsetTracker {
+	return &offsetTracker{records: records, done: make([]bool, len(records)), handled: handled}
+}
+
+// complete marks the i'th record of the run handled.
+func (t *offsetTracker) complete(i int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[i] = true
+	for t.next < len(t.done) && t.done[t.next] {
+		t.next++
+	}
+	if t.next > 0 {
+		t.handled.Store(t.records[t.next-1].Offset)
+	}
+}
+
+// keyLane returns which of n lanes a record with key is handled in. Records
+// with the same key, or with none, share a lane.
+func keyLane(key []byte, n int) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(n))
+}
+
+// consumeByKey consumes records across the gateway's key lanes, each
+// handling its records in order, so that records with different keys are
+// handled concurrently while those with the same key stay in order. It
+// stops at the first error; the batch is then aborted, so nothing past the
+// lowest unhandled offset is committed.
+func (w *partitionWorker) consumeByKey(ctx context.Context, records []*kgo.Record) error {
+	n := w.s.keyParallelism
+	lanes := make([][]int, n)
+	for i, record := range records {
+		lane := keyLane(record.Key, n)
+		lanes[lane] = append(lanes[lane], i)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tracker := newOffsetTracker(records, &w.handled)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, lane := range lanes {
+		if len(lane) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range lane {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := w.s.consume(ctx, records[i]); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				tracker.complete(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []int
+		// want is the offset handled after each record completes.
+		want []int64
+	}{
+		{"in order", []int{0, 1, 2, 3}, []int64{10, 11, 12, 13}},
+		{"reversed", []int{3, 2, 1, 0}, []int64{-1, -1, -1, 13}},
+		{"gap", []int{0, 2, 3, 1}, []int64{10, 10, 10, 13}},
+		{"interleaved", []int{1, 0, 3, 2}, []int64{-1, 11, 11, 13}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []*kgo.Record
+			for offset := range int64(4) {
+				records = append(records, &kgo.Record{Offset: 10 + offset})
+			}
+			var handled atomic.Int64
+			handled.Store(-1)
+			tracker := newOffsetTracker(records, &handled)
+
+			var got []int64
+			for _, i := range tt.order {
+				tracker.complete(i)
+				got = append(got, handled.Load())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got handled %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyLane(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 16} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			for i := range 100 {
+				key := []byte(fmt.Sprintf("key-%d", i))
+				lane := keyLane(key, n)
+				if lane < 0 || lane >= n {
+					t.Fatalf("%s: got lane %d of %d", key, lane, n)
+				}
+				if again := keyLane(slices.Clone(key), n); again != lane {
+					t.Fatalf("%s: got lanes %d and %d", key, lane, again)
+				}
+			}
+			if keyLane(nil, n) != keyLane([]byte{}, n) {
+				t.Error("records without a key are spread over lanes")
+			}
+		})
+	}
+
+	lanes := make(map[int]bool)
+	for i := range 100 {
+		lanes[keyLane([]byte(fmt.Sprintf("key-%d", i)), 4)] = true
+	}
+	if len(lanes) != 4 {
+		t.Errorf("got %d of 4 lanes used by 100 keys", len(lanes))
+	}
+}
+
+func TestConsumeByKey(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string][]int64)
+	handler := HandlerFunc(func(_ context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+		time.Sleep(time.Duration(rand.N(500)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		handled[string(record.Key)] = append(handled[string(record.Key)], record.Offset)
+		return nil, nil
+	})
+	gw, _ := newTestGateway(t, handler, WithKeyParallelism(4))
+	w := &partitionWorker{s: gw, tp: topicPartition{"topic", 0}}
+
+	var records []*kgo.Record
+	want := make(map[string][]int64)
+	for offset := range int64(40) {
+		key := fmt.Sprintf("key-%d", offset%5)
+		records = append(records, &kgo.Record{Topic: "topic", Key: []byte(key), Offset: offset})
+		want[key] = append(want[key], offset)
+	}
+
+	if err := w.consumeByKey(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	for key, offsets := range want {
+		if got := handled[key]; !slices.Equal(got, offsets) {
+			t.Errorf("%s: got offsets %v, want %v", key, got, offsets)
+		}
+	}
+	if got := w.handled.Load(); got != 39 {
+		t.Errorf("got handled %d, want 39", got)
+	}
+}
+
+func TestConsumeByKeyStopsAtError(t *testing.T) {
+	boom := errors.New("boom")
+	handler := HandlerFunc(func(_ context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+		if record.Offset == 2 {
+			return nil, boom
+		}
+		return nil, nil
+	})
+	gw, _ := newTestGateway(t, handler, WithKeyParallelism(2))
+	w := &partitionWorker{s: gw, tp: topicPartition{"topic", 0}}
+	w.handled.Store(-1)
+
+	var records []*kgo.Record
+	for offset := range int64(6) {
+		records = append(records, &kgo.Record{Topic: "topic", Key: []byte(fmt.Sprint(offset)), Offset: offset})
+	}
+
+	if err := w.consumeByKey(context.Background(), records); !errors.Is(err, boom) {
+		t.Fatalf("got error %v, want %v", err, boom)
+	}
+	if got := w.handled.Load(); got >= 2 {
+		t.Errorf("got handled %d, past the failed record", got)
+	}
+}
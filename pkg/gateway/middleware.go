@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// LoggingMiddleware logs each record handled, at debug level. Failures are
+// logged by the gateway itself, so are not repeated at a higher level here.
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+			began := time.Now()
+			records, err := next.Handle(ctx, record)
+
+			args := []any{"topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "produced", len(records), "duration", time.Since(began)}
+			if err != nil {
+				args = append(args, "error", err)
+			}
+			logger.DebugContext(ctx, "handled record", args...)
+
+			return records, err
+		})
+	}
+}
+
+// TimingMiddleware reports how long each record took to handle, and how
+// handling ended, to observe; typically to record a metric.
+func TimingMiddleware(observe func(ctx context.Context, record *kgo.Record, elapsed time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+			began := time.Now()
+			records, err := next.Handle(ctx, record)
+			observe(ctx, record, time.Since(began), err)
+			return records, err
+		})
+	}
+}
+
+// RecoveryMiddleware turns a panicking handler into a permanent error, so
+// that the record is skipped rather than the gateway crashing.
+func RecoveryMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, record *kgo.Record) (records []*kgo.Record, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					records = nil
+					err = daemon.Permanent(fmt.Errorf("handler panicked: %v\n%s", r, debug.Stack()))
+				}
+			}()
+
+			return next.Handle(ctx, record)
+		})
+	}
+}
+
+// TimeoutMiddleware gives each record d to be handled in. The handler must
+// honour ctx for this to take effect; if it runs out of time, the error is
+// transient so the record is retried.
+func TimeoutMiddleware(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+			tctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			records, err := next.Handle(tctx, record)
+			if err != nil && errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil, daemon.Transient(fmt.Errorf("handler timed out after %s: %w", d, err))
+			}
+			return records, err
+		})
+	}
+}
+
+// TracingMiddleware starts a span for each record, continuing the trace
+// the record carries or starting a new one, and passes it on to the
+// records produced in the record's place through the traceparent header.
+func TracingMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+			trace, ok := daemon.TraceFromContext(ctx)
+			if !ok {
+				trace.TraceID = randomHex(16)
+			}
+			trace.SpanID = randomHex(8)
+			ctx = daemon.ContextWithTrace(ctx, trace)
+
+			records, err := next.Handle(ctx, record)
+
+			traceParent := fmt.Sprintf("00-%s-%s-01", trace.TraceID, trace.SpanID)
+			for _, r := range records {
+				if _, exists := header(r, TraceParentHeader); !exists {
+					r.Headers = append(r.Headers, kgo.RecordHeader{Key: TraceParentHeader, Value: []byte(traceParent)})
+				}
+			}
+
+			return records, err
+		})
+	}
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
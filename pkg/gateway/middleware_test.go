@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+				calls = append(calls, name+" in")
+				records, err := next.Handle(ctx, record)
+				calls = append(calls, name+" out")
+				return records, err
+			})
+		}
+	}
+	handler := HandlerFunc(func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	})
+
+	Chain(trace("a"), trace("b"))(handler).Handle(context.Background(), &kgo.Record{})
+
+	want := []string{"a in", "b in", "handler", "b out", "a out"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	handler := RecoveryMiddleware()(HandlerFunc(func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+		panic("boom")
+	}))
+
+	records, err := handler.Handle(context.Background(), &kgo.Record{})
+	if records != nil {
+		t.Errorf("got records %v from a panicking handler", records)
+	}
+	if !daemon.IsPermanent(err) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("got error %v, want a permanent error carrying the panic", err)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name      string
+		handler   HandlerFunc
+		transient bool
+	}{
+		{
+			name: "timed out",
+			handler: func(ctx context.Context, _ *kgo.Record) ([]*kgo.Record, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			transient: true,
+		},
+		{
+			name: "failed in time",
+			handler: func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+				return nil, errHandler
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TimeoutMiddleware(10*time.Millisecond)(tt.handler).Handle(context.Background(), &kgo.Record{})
+			if got := daemon.Classify(err) == daemon.ClassTransient; got != tt.transient {
+				t.Errorf("got error %v, marked transient %t, want %t", err, got, tt.transient)
+			}
+		})
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	handler := TracingMiddleware()(HandlerFunc(func(ctx context.Context, _ *kgo.Record) ([]*kgo.Record, error) {
+		trace, ok := daemon.TraceFromContext(ctx)
+		if !ok || trace.TraceID != "trace" || trace.SpanID == "" {
+			t.Errorf("handler got trace %+v, want a new span of the record's trace", trace)
+		}
+		return []*kgo.Record{{}, {Headers: []kgo.RecordHeader{{Key: TraceParentHeader, Value: []byte("kept")}}}}, nil
+	}))
+
+	ctx := daemon.ContextWithTrace(context.Background(), daemon.Trace{TraceID: "trace", SpanID: "parent"})
+	records, err := handler.Handle(ctx, &kgo.Record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tp, _ := header(records[0], TraceParentHeader); !strings.HasPrefix(tp, "00-trace-") || strings.Contains(tp, "parent") {
+		t.Errorf("got traceparent %q, want the handler's span of the trace", tp)
+	}
+	if tp, _ := header(records[1], TraceParentHeader); tp != "kept" {
+		t.Errorf("got traceparent %q, want the handler's own kept", tp)
+	}
+}
@@ -1,5 +1,13 @@
 package gateway
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
 type Option func(*Gateway) error
 
 func WithBrokerURIs(uris []string) Option {
@@ -37,12 +45,158 @@ func WithHandler(handler Handler) Option {
 	}
 }
 
-// func WithExponentialBackoff(initial time.Duration, limit int) Option {
-// 	return func(gw *Gateway) error {
-// 		gw.backoff = &ExponentialBackoff{
-// 			initial: initial,
-// 			limit:   limit,
-// 		}
-// 		return nil
-// 	}
-// }
+// WithMiddleware wraps the handler with middleware, the first outermost.
+// It may be given more than once; later middleware is wrapped inside
+// earlier.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(gw *Gateway) error {
+		gw.middleware = append(gw.middleware, middleware...)
+		return nil
+	}
+}
+
+// WithTransactionalID sets the ID the gateway's transactions are made
+// under. It must be unique to each running instance. By default it is made
+// from the gateway's name, the host name and process ID, and the replica
+// index within a Pool, so it changes when the instance is restarted; to
+// have the brokers fence off the instance a restart replaced, give each a
+// stable ID of its own, such as its pod name in a StatefulSet.
+func WithTransactionalID(id string) Option {
+	return func(gw *Gateway) error {
+		gw.transactionalID = id
+		return nil
+	}
+}
+
+// WithBatching sets how many records the gateway handles in each
+// transaction. By default it handles each poll in one.
+func WithBatching(batching Batching) Option {
+	return func(gw *Gateway) error {
+		gw.batching = batching
+		return nil
+	}
+}
+
+// WithPartitionBuffer sets how many polls' worth of records each
+// partition's worker queues before polling waits for it to catch up.
+func WithPartitionBuffer(n int) Option {
+	return func(gw *Gateway) error {
+		if n < 1 {
+			return fmt.Errorf("partition buffer must be at least 1, got %d", n)
+		}
+		gw.partitionBuffer = n
+		return nil
+	}
+}
+
+// WithKeyParallelism spreads each partition's records across n lanes by
+// hashing their keys, so that a slow handler can work through a hot
+// partition without it being repartitioned. Records with the same key are
+// still handled in order; records without one share a lane. Offsets are
+// only committed once every record before them has been handled.
+func WithKeyParallelism(n int) Option {
+	return func(gw *Gateway) error {
+		if n < 1 {
+			return fmt.Errorf("key parallelism must be at least 1, got %d", n)
+		}
+		gw.keyParallelism = n
+		return nil
+	}
+}
+
+// WithCorrelationHeader sets the record header that carries the correlation
+// ID. It is attached to everything logged while handling the record, and
+// copied onto the records the handler produces.
+func WithCorrelationHeader(key string) Option {
+	return func(gw *Gateway) error {
+		gw.correlationHeader = key
+		return nil
+	}
+}
+
+// WithKafkaLogLevel sets the level at which the Kafka client's own logs are
+// passed on to the gateway's logger.
+func WithKafkaLogLevel(level kgo.LogLevel) Option {
+	return func(gw *Gateway) error {
+		gw.kafkaLogLevel = level
+		return nil
+	}
+}
+
+// WithRecordLogSampling samples the warnings logged for individual records,
+// keyed by message and level. The sampling interval must be positive.
+func WithRecordLogSampling(sampling daemon.Sampling) Option {
+	return func(gw *Gateway) error {
+		if sampling.Interval <= 0 {
+			return fmt.Errorf("record log sampling interval must be positive, got %s", sampling.Interval)
+		}
+		gw.recordSampling = &sampling
+		return nil
+	}
+}
+
+// WithPollRetry sets how often, and after how long, a failed poll is
+// retried before Run gives up. By default it is retried 4 times, a second
+// apart.
+func WithPollRetry(policy daemon.RetryPolicy) Option {
+	return func(gw *Gateway) error {
+		gw.pollRetry = policy
+		return nil
+	}
+}
+
+// WithRecordRetry sets how often, and after how long, a record that fails
+// to be handled is retried in place, before the failure is passed on to
+// the poll loop. By default it is not.
+func WithRecordRetry(policy daemon.RetryPolicy) Option {
+	return func(gw *Gateway) error {
+		gw.recordRetry = policy
+		return nil
+	}
+}
+
+// WithDeadLetterTopic sends records the handler fails on, once their
+// retries are used up, to topic, along with headers describing the
+// failure. Without it, records that fail with a permanent error are
+// skipped, but any other failure aborts the batch: its records are handled
+// again, one per transaction, under the poll retry policy, and once that
+// is used up Run returns the error.
+func WithDeadLetterTopic(topic string) Option {
+	return func(gw *Gateway) error {
+		gw.deadLetterTopic = topic
+		return nil
+	}
+}
+
+// WithRetryTiers retries records the handler fails on, once their
+// in-place retries are used up, through a topic per delay (see
+// RetryTopic), consumed alongside the gateway's own. Each record waits out
+// its tier's delay without holding up the partition it came from; after
+// the last tier it goes to the dead-letter topic, if there is one.
+// Permanent errors skip the tiers. A batch that takes in a record before it
+// is due is aborted, and handled again while the record's tier partition
+// is paused.
+func WithRetryTiers(delays ...time.Duration) Option {
+	return func(gw *Gateway) error {
+		gw.retryTiers = nil
+		for _, d := range delays {
+			if d <= 0 {
+				return fmt.Errorf("retry tier delay must be positive, got %s", d)
+			}
+			gw.retryTiers = append(gw.retryTiers, retryTier{delay: d})
+		}
+		return nil
+	}
+}
+
+// WithExponentialBackoff retries failed polls up to limit times, waiting
+// initial before the first retry and twice as long before each after.
+func WithExponentialBackoff(initial time.Duration, limit int) Option {
+	return func(gw *Gateway) error {
+		gw.pollRetry = daemon.RetryPolicy{
+			MaxRetries: limit,
+			Backoff:    daemon.ExponentialBackoff{Initial: initial},
+		}
+		return nil
+	}
+}
@@ -0,0 +1,242 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// DefaultPartitionBuffer is how many polls' worth of records each
+// partition worker queues, unless configured otherwise.
+const DefaultPartitionBuffer = 4
+
+// partitionWork is a run of one partition's records, to be consumed as
+// part of b.
+type partitionWork struct {
+	b       *batch
+	records []*kgo.Record
+}
+
+// partitionWorker consumes the records of a single assigned partition, in
+// order, so that partitions progress independently of one another.
+type partitionWorker struct {
+	s    *Gateway
+	tp   topicPartition
+	work chan partitionWork
+	stop chan struct{}
+	done chan struct{}
+
+	// mu is held while records are enqueued, so that the work channel is
+	// only closed once nothing can be sending on it.
+	mu     sync.Mutex
+	closed bool
+
+	// ctx is cancelled when the worker is stopped, interrupting the records
+	// in hand.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// handled is the offset up to which every record has been consumed,
+	// or -1.
+	handled atomic.Int64
+}
+
+func (s *Gateway) newPartitionWorker(tp topicPartition) *partitionWorker {
+	w := &partitionWorker{
+		s:    s,
+		tp:   tp,
+		work: make(chan partitionWork, s.partitionBuffer),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.handled.Store(-1)
+
+	go w.run()
+	return w
+}
+
+func (w *partitionWorker) run() {
+	defer close(w.done)
+
+	for {
+		select {
+		case <-w.stop:
+			// Anything still queued belongs to a transaction the
+			// session will abort, as the partition has been revoked.
+			for item := range w.work {
+				item.b.finish(nil)
+			}
+			return
+		case item, ok := <-w.work:
+			if !ok {
+				return
+			}
+			if w.ctx.Err() != nil {
+				// Stopped while the item was queued.
+				item.b.finish(nil)
+				continue
+			}
+			err := w.consume(item)
+			if w.ctx.Err() != nil {
+				// Interrupted by the partition being revoked, which is not
+				// the records' fault.
+				err = nil
+			}
+			item.b.finish(err)
+		}
+	}
+}
+
+func (w *partitionWorker) consume(item partitionWork) error {
+	ctx, cancel := context.WithCancel(item.b.ctx)
+	defer cancel()
+	defer context.AfterFunc(w.ctx, cancel)()
+
+	if w.s.keyParallelism > 1 {
+		return w.consumeByKey(ctx, item.records)
+	}
+	return w.consumeInOrder(ctx, item.records)
+}
+
+func (w *partitionWorker) consumeInOrder(ctx context.Context, records []*kgo.Record) error {
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := w.s.consume(ctx, record); err != nil {
+			return err
+		}
+		w.handled.Store(record.Offset)
+	}
+	return nil
+}
+
+// enqueue hands records to the worker, waiting for room in its buffer. If
+// the worker has been stopped, the records are dropped.
+func (w *partitionWorker) enqueue(b *batch, records []*kgo.Record) {
+	b.start()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		b.finish(nil)
+		return
+	}
+	select {
+	case w.work <- partitionWork{b: b, records: records}:
+	case <-w.stop:
+		b.finish(nil)
+	}
+}
+
+// halt stops the worker, interrupting the records in hand. Once nothing
+// can be enqueued, the work channel is closed, so that the worker finishes
+// everything still queued before it is done.
+func (w *partitionWorker) halt() {
+	w.cancel()
+	close(w.stop)
+
+	w.mu.Lock()
+	w.closed = true
+	close(w.work)
+	w.mu.Unlock()
+}
+
+// dispatch hands the records in fetches to their partitions' workers.
+// Records of partitions that have since been revoked are dropped; the
+// session aborts the transaction they would have been part of.
+func (s *Gateway) dispatch(b *batch, fetches kgo.Fetches) {
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if len(p.Records) == 0 {
+			return
+		}
+
+		s.workersMu.Lock()
+		w, ok := s.workers[topicPartition{p.Topic, p.Partition}]
+		s.workersMu.Unlock()
+		if !ok {
+			s.logger.DebugContext(b.ctx, "dropping records of revoked partition", "topic", p.Topic, "partition", p.Partition, "records", len(p.Records))
+			return
+		}
+
+		b.dispatched(w.tp, p.Records)
+		w.enqueue(b, p.Records)
+	})
+}
+
+// startWorkers starts a worker for each of the given partitions that does
+// not have one.
+func (s *Gateway) startWorkers(partitions map[string][]int32) {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	for topic, partitions := range partitions {
+		for _, partition := range partitions {
+			tp := topicPartition{topic, partition}
+			if _, ok := s.workers[tp]; !ok {
+				s.workers[tp] = s.newPartitionWorker(tp)
+			}
+		}
+	}
+}
+
+// stopWorkers stops the workers of the given partitions, or of every
+// partition if partitions is nil, and waits for them to finish, or for ctx
+// to be done.
+func (s *Gateway) stopWorkers(ctx context.Context, partitions map[string][]int32) error {
+	s.workersMu.Lock()
+	var stopping []*partitionWorker
+	for tp, w := range s.workers {
+		if partitions != nil && !containsPartition(partitions, tp) {
+			continue
+		}
+		delete(s.workers, tp)
+		stopping = append(stopping, w)
+	}
+	s.workersMu.Unlock()
+
+	for _, w := range stopping {
+		w.halt()
+	}
+	for _, w := range stopping {
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s[%d] to stop: %w", w.tp.topic, w.tp.partition, ctx.Err())
+		}
+	}
+	return nil
+}
+
+func containsPartition(partitions map[string][]int32, tp topicPartition) bool {
+	for _, p := range partitions[tp.topic] {
+		if p == tp.partition {
+			return true
+		}
+	}
+	return false
+}
+
+// Progress returns the offset of the last record handled on each partition
+// assigned to the gateway, or -1 for those it has handled nothing on yet.
+// Offsets handled in a transaction that is later aborted are included.
+func (s *Gateway) Progress() map[string]map[int32]int64 {
+	s.workersMu.Lock()
+	workers := maps.Clone(s.workers)
+	s.workersMu.Unlock()
+
+	progress := make(map[string]map[int32]int64)
+	for tp, w := range workers {
+		if progress[tp.topic] == nil {
+			progress[tp.topic] = make(map[int32]int64)
+		}
+		progress[tp.topic][tp.partition] = w.handled.Load()
+	}
+	return progress
+}
@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestGatewayKeepsPartitionOrder(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	handled := make(map[int32][]int64)
+	handler := HandlerFunc(func(_ context.Context, record *kgo.Record) ([]*kgo.Record, error) {
+		time.Sleep(time.Duration(rand.N(500)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		handled[record.Partition] = append(handled[record.Partition], record.Offset)
+		return nil, nil
+	})
+	gw, session := newTestGateway(t, handler, WithBatching(Batching{MaxRecords: 10, MaxDuration: time.Minute}))
+	session.rebalance(ctx, gw, map[string][]int32{"topic": {0, 1}})
+
+	session.queue("topic", 0, 0, 1, 2)
+	session.queue("topic", 1, 0, 1, 2)
+	session.queue("topic", 0, 3, 4)
+	session.queue("topic", 1, 3, 4)
+	if err := gw.handle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []kgo.TransactionEndTry{kgo.TryCommit}; !slices.Equal(session.ends, want) {
+		t.Errorf("got transaction ends %v, want %v", session.ends, want)
+	}
+	want := []int64{0, 1, 2, 3, 4}
+	for _, partition := range []int32{0, 1} {
+		if got := handled[partition]; !slices.Equal(got, want) {
+			t.Errorf("partition %d: got offsets %v, want %v", partition, got, want)
+		}
+	}
+}
+
+func TestPartitionWorkerEnqueueAfterStop(t *testing.T) {
+	s := &Gateway{partitionBuffer: 1}
+	w := s.newPartitionWorker(topicPartition{"topic", 0})
+	w.halt()
+	<-w.done
+
+	b := newBatch(context.Background())
+	w.enqueue(b, []*kgo.Record{{Offset: 1}})
+
+	waitBatch(t, b)
+}
+
+func TestPartitionWorkerFinishesQueuedWorkWhenStopped(t *testing.T) {
+	s := &Gateway{partitionBuffer: 4}
+	w := &partitionWorker{
+		s:    s,
+		tp:   topicPartition{"topic", 0},
+		work: make(chan partitionWork, s.partitionBuffer),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	// Queue work before the worker runs, so that it is all still queued
+	// when the worker is stopped.
+	b := newBatch(context.Background())
+	for i := range 3 {
+		w.enqueue(b, []*kgo.Record{{Offset: int64(i)}})
+	}
+	w.halt()
+	go w.run()
+
+	waitBatch(t, b)
+}
+
+func waitBatch(t *testing.T, b *batch) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if done, _ := b.waitContext(ctx); !done {
+		t.Fatal("batch was never finished")
+	}
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// AssignmentListener is implemented by handlers that keep state per
+// partition. PartitionsAssigned is called before any of the assigned
+// partitions' records are handled; PartitionsRevoked once the revoked
+// partitions' records are no longer being handled, including when the
+// partitions were lost.
+type AssignmentListener interface {
+	PartitionsAssigned(ctx context.Context, assigned map[string][]int32)
+	PartitionsRevoked(ctx context.Context, revoked map[string][]int32)
+}
+
+// onAssigned starts workers for the assigned partitions.
+func (s *Gateway) onAssigned(ctx context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	s.logger.InfoContext(ctx, "partitions assigned", "partitions", assigned)
+
+	s.startWorkers(assigned)
+	if s.listener != nil {
+		s.listener.PartitionsAssigned(ctx, assigned)
+	}
+}
+
+// onRevoked stops the revoked partitions' workers before the rebalance
+// goes on, interrupting the records they have in hand and dropping those
+// queued. Once partitions are revoked, the session will not commit the
+// transaction in flight, so the records are handled again, by the
+// partitions' next owner; everything handled before was committed with its
+// own transaction. The other partitions' workers carry on. Until that
+// transaction ends, the session calls this no more, which is why an idle
+// gateway ends it anyway.
+//
+// The rebalance waits on this, so the workers are given no more than half
+// the rebalance timeout to stop, leaving the rest for the gateway to
+// rejoin the group.
+func (s *Gateway) onRevoked(ctx context.Context, client *kgo.Client, revoked map[string][]int32) {
+	s.revoked.Store(true)
+	if len(revoked) == 0 {
+		return
+	}
+	s.logger.InfoContext(ctx, "partitions revoked", "partitions", revoked)
+
+	timeout, _ := client.OptValue(kgo.RebalanceTimeout).(time.Duration)
+	stopCtx, cancel := context.WithTimeout(ctx, timeout/2)
+	defer cancel()
+	if err := s.stopWorkers(stopCtx, revoked); err != nil {
+		s.logger.WarnContext(ctx, "revoked partition's worker did not stop in time; its handler is ignoring ctx", "error", err)
+	}
+
+	s.release(client, revoked)
+	if s.listener != nil {
+		s.listener.PartitionsRevoked(ctx, revoked)
+	}
+}
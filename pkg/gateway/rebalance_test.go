@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// assignmentRecorder is a handler that records the assignment changes it
+// is told of.
+type assignmentRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *assignmentRecorder) Handle(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+	return nil, nil
+}
+
+func (r *assignmentRecorder) PartitionsAssigned(_ context.Context, assigned map[string][]int32) {
+	r.record("assigned", assigned)
+}
+
+func (r *assignmentRecorder) PartitionsRevoked(_ context.Context, revoked map[string][]int32) {
+	r.record("revoked", revoked)
+}
+
+func (r *assignmentRecorder) record(event string, partitions map[string][]int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, topic := range slices.Sorted(maps.Keys(partitions)) {
+		event += fmt.Sprintf(" %s%v", topic, partitions[topic])
+	}
+	r.events = append(r.events, event)
+}
+
+func TestGatewayIdleRebalances(t *testing.T) {
+	ctx := context.Background()
+	recorder := &assignmentRecorder{}
+	gw, session := newTestGateway(t, recorder)
+
+	session.rebalance(ctx, gw, map[string][]int32{"topic": {0, 1}})
+	for _, assigned := range []map[string][]int32{
+		{"topic": {1}},
+		{"topic": {0}},
+	} {
+		if err := gw.handle(ctx); err != nil {
+			t.Fatal(err)
+		}
+		session.rebalance(ctx, gw, assigned)
+	}
+
+	want := []string{
+		"assigned topic[0 1]",
+		"revoked topic[0 1]",
+		"assigned topic[1]",
+		"revoked topic[1]",
+		"assigned topic[0]",
+	}
+	if !slices.Equal(recorder.events, want) {
+		t.Errorf("got events %q, want %q", recorder.events, want)
+	}
+
+	if got := gw.Progress(); !maps.Equal(got["topic"], map[int32]int64{0: -1}) {
+		t.Errorf("got workers for %v, want only topic[0]", got)
+	}
+}
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Headers tracking a record through the retry tiers. The source headers
+// name where the record was first consumed from.
+const (
+	RetryTierHeader            = "retry-tier"
+	RetryDelayHeader           = "retry-delay"
+	RetryDueHeader             = "retry-due"
+	RetryAttemptsHeader        = "retry-attempts"
+	RetryErrorHeader           = "retry-error"
+	RetrySourceTopicHeader     = "retry-source-topic"
+	RetrySourcePartitionHeader = "retry-source-partition"
+	RetrySourceOffsetHeader    = "retry-source-offset"
+)
+
+// retryTier is a topic records wait in for delay before they are retried.
+type retryTier struct {
+	delay time.Duration
+	topic string
+}
+
+// RetryTopic names the topic that records from topic wait in for delay,
+// such as "orders.retry.5s" or "orders.retry.1m".
+func RetryTopic(topic string, delay time.Duration) string {
+	name := delay.String()
+	// Drop trailing zero units: "1m0s" reads better as "1m".
+	for _, zero := range []string{"0s", "0m"} {
+		if trimmed := strings.TrimSuffix(name, zero); strings.HasSuffix(trimmed, "h") || strings.HasSuffix(trimmed, "m") {
+			name = trimmed
+		}
+	}
+	return topic + ".retry." + name
+}
+
+// topics returns the topics the gateway consumes: its own, and those of
+// its retry tiers.
+func (s *Gateway) topics() []string {
+	topics := []string{s.topic}
+	for _, tier := range s.retryTiers {
+		topics = append(topics, tier.topic)
+	}
+	return topics
+}
+
+// nextRetry returns a copy of record for the retry tier after the one it
+// is in, or false if it has been through every tier.
+func (s *Gateway) nextRetry(record *kgo.Record, err error, attempts int) (*kgo.Record, bool) {
+	tier := 0
+	if t, ok := headerInt(record, RetryTierHeader); ok {
+		tier = int(t) + 1
+	}
+	if tier >= len(s.retryTiers) {
+		return nil, false
+	}
+	next := s.retryTiers[tier]
+
+	topic, partition, offset := origin(record)
+	headers := slices.DeleteFunc(slices.Clone(record.Headers), func(h kgo.RecordHeader) bool {
+		return strings.HasPrefix(h.Key, "retry-")
+	})
+	headers = append(headers,
+		kgo.RecordHeader{Key: RetryTierHeader, Value: []byte(strconv.Itoa(tier))},
+		kgo.RecordHeader{Key: RetryDelayHeader, Value: []byte(next.delay.String())},
+		kgo.RecordHeader{Key: RetryDueHeader, Value: []byte(strconv.FormatInt(time.Now().Add(next.delay).UnixMilli(), 10))},
+		kgo.RecordHeader{Key: RetryAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+		kgo.RecordHeader{Key: RetryErrorHeader, Value: []byte(err.Error())},
+		kgo.RecordHeader{Key: RetrySourceTopicHeader, Value: []byte(topic)},
+		kgo.RecordHeader{Key: RetrySourcePartitionHeader, Value: []byte(strconv.FormatInt(int64(partition), 10))},
+		kgo.RecordHeader{Key: RetrySourceOffsetHeader, Value: []byte(strconv.FormatInt(offset, 10))},
+	)
+
+	return &kgo.Record{
+		Topic:   next.topic,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+	}, true
+}
+
+// attempts returns the number of times record has been handled, counting
+// the retries made in place this time and those made in earlier tiers.
+func attempts(record *kgo.Record, retries int) int {
+	prior, _ := headerInt(record, RetryAttemptsHeader)
+	return int(prior) + retries + 1
+}
+
+// origin returns where record was first consumed from, before it went
+// through the retry tiers.
+func origin(record *kgo.Record) (string, int32, int64) {
+	topic, ok := header(record, RetrySourceTopicHeader)
+	if !ok {
+		return record.Topic, record.Partition, record.Offset
+	}
+	partition, _ := headerInt(record, RetrySourcePartitionHeader)
+	offset, _ := headerInt(record, RetrySourceOffsetHeader)
+	return topic, int32(partition), offset
+}
+
+// due returns when record may be retried; records that are not waiting in
+// a retry tier are due at once.
+func due(record *kgo.Record) time.Time {
+	ms, ok := headerInt(record, RetryDueHeader)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func headerInt(record *kgo.Record, key string) (int64, bool) {
+	v, ok := header(record, key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, err == nil
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// errHeld fails the batch in flight when it takes in a record that is not
+// yet due.
+var errHeld = errors.New("record not yet due")
+
+// holdNotDue holds the partitions in fetches that have a record not yet
+// due, reporting whether there were any. Only the poll loop pauses and
+// resumes partitions, so that it never races a fetch.
+func (s *Gateway) holdNotDue(fetches kgo.Fetches) bool {
+	held := false
+	now := time.Now()
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		for _, record := range p.Records {
+			if due(record).After(now) {
+				s.hold(record)
+				held = true
+				return
+			}
+		}
+	})
+	return held
+}
+
+// hold pauses record's partition until record, which is not yet due, is.
+// Other partitions, including the main topic's, carry on.
+//
+// Once polled, a record's offset is committed with the transaction, so the
+// batch that took record in is aborted rather than committed: the session
+// then rewinds every partition to its committed offset, and record is
+// fetched again once its partition is resumed. Nothing is kept in memory,
+// so nothing is lost if the gateway stops in the meantime.
+func (s *Gateway) hold(record *kgo.Record) {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+
+	s.session.Client().PauseFetchPartitions(map[string][]int32{record.Topic: {record.Partition}})
+	s.held[topicPartition{record.Topic, record.Partition}] = due(record)
+}
+
+// resumeDue resumes the held partitions whose next record has come due.
+func (s *Gateway) resumeDue() {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+
+	now := time.Now()
+	for tp, at := range s.held {
+		if at.After(now) {
+			continue
+		}
+		delete(s.held, tp)
+		s.session.Client().ResumeFetchPartitions(map[string][]int32{tp.topic: {tp.partition}})
+	}
+}
+
+// release forgets the held partitions the gateway no longer owns, and
+// resumes them so that they are fetched if they are assigned back.
+func (s *Gateway) release(client *kgo.Client, revoked map[string][]int32) {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+
+	for topic, partitions := range revoked {
+		for _, partition := range partitions {
+			delete(s.held, topicPartition{topic, partition})
+		}
+	}
+	client.ResumeFetchPartitions(revoked)
+}
+
+// pollContext returns a context for polling that ends when the first held
+// partition comes due, so that it is resumed without waiting on new
+// records.
+func (s *Gateway) pollContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+
+	var next time.Time
+	for _, at := range s.held {
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, next)
+}
+
+// scheduleRetry returns the record to produce in place of one the handler
+// failed on, if there is a retry tier left for it.
+func (s *Gateway) scheduleRetry(ctx context.Context, record *kgo.Record, err error, retries int) (*kgo.Record, bool) {
+	if daemon.IsPermanent(err) {
+		return nil, false
+	}
+
+	retry, ok := s.nextRetry(record, err, attempts(record, retries))
+	if ok {
+		s.recordLogger.InfoContext(ctx, "scheduling record retry", "topic", record.Topic, "partition", record.Partition, "offset", record.Offset, "retry_topic", retry.Topic, "error", err)
+	}
+	return retry, ok
+}
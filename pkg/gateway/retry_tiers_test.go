@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestRetryTopic(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{500 * time.Millisecond, "orders.retry.500ms"},
+		{5 * time.Second, "orders.retry.5s"},
+		{time.Minute, "orders.retry.1m"},
+		{90 * time.Second, "orders.retry.1m30s"},
+		{time.Hour, "orders.retry.1h"},
+		{90 * time.Minute, "orders.retry.1h30m"},
+		{time.Hour + time.Second, "orders.retry.1h0m1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.delay.String(), func(t *testing.T) {
+			if got := RetryTopic("orders", tt.delay); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRetry(t *testing.T) {
+	gw, _ := newTestGateway(t, HandlerFunc(func(context.Context, *kgo.Record) ([]*kgo.Record, error) {
+		return nil, nil
+	}), WithRetryTiers(time.Second, time.Minute))
+
+	record := &kgo.Record{
+		Topic:     "topic",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("key"),
+		Value:     []byte("value"),
+		Headers:   []kgo.RecordHeader{{Key: "trace", Value: []byte("abc")}},
+	}
+
+	tests := []struct {
+		topic    string
+		tier     string
+		delay    time.Duration
+		attempts int
+	}{
+		{"topic.retry.1s", "0", time.Second, 3},
+		{"topic.retry.1m", "1", time.Minute, 5},
+	}
+	for _, tt := range tests {
+		before := time.Now()
+		retry, ok := gw.nextRetry(record, errors.New("boom"), tt.attempts)
+		if !ok {
+			t.Fatalf("no retry from %s", record.Topic)
+		}
+
+		if retry.Topic != tt.topic {
+			t.Errorf("got topic %q, want %q", retry.Topic, tt.topic)
+		}
+		if string(retry.Key) != "key" || string(retry.Value) != "value" {
+			t.Errorf("got key %q and value %q, want the original record's", retry.Key, retry.Value)
+		}
+		want := map[string]string{
+			"trace":                    "abc",
+			RetryTierHeader:            tt.tier,
+			RetryDelayHeader:           tt.delay.String(),
+			RetryAttemptsHeader:        strconv.Itoa(tt.attempts),
+			RetryErrorHeader:           "boom",
+			RetrySourceTopicHeader:     "topic",
+			RetrySourcePartitionHeader: "3",
+			RetrySourceOffsetHeader:    "42",
+		}
+		for key, value := range want {
+			if got, _ := header(retry, key); got != value {
+				t.Errorf("%s: got header %s %q, want %q", tt.topic, key, got, value)
+			}
+		}
+		if n := len(retry.Headers); n != len(want)+1 {
+			t.Errorf("%s: got %d headers, want %d", tt.topic, n, len(want)+1)
+		}
+		if at := due(retry); at.Before(before.Add(tt.delay).Truncate(time.Millisecond)) || at.After(time.Now().Add(tt.delay)) {
+			t.Errorf("%s: got due %s, want %s after %s", tt.topic, at, tt.delay, before)
+		}
+
+		// Consume the retry from its tier, as the gateway would.
+		retry.Partition, retry.Offset = 0, 7
+		record = retry
+	}
+
+	if _, ok := gw.nextRetry(record, errors.New("boom"), 6); ok {
+		t.Error("got a retry after the last tier")
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kgo.RecordHeader
+		retries int
+		want    int
+	}{
+		{"first", nil, 0, 1},
+		{"in place", nil, 2, 3},
+		{"from a tier", []kgo.RecordHeader{{Key: RetryAttemptsHeader, Value: []byte("3")}}, 0, 4},
+		{"in place from a tier", []kgo.RecordHeader{{Key: RetryAttemptsHeader, Value: []byte("3")}}, 2, 6},
+		{"malformed", []kgo.RecordHeader{{Key: RetryAttemptsHeader, Value: []byte("x")}}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attempts(&kgo.Record{Headers: tt.headers}, tt.retries); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDue(t *testing.T) {
+	at := time.UnixMilli(1_700_000_000_000)
+	tests := []struct {
+		name    string
+		headers []kgo.RecordHeader
+		want    time.Time
+	}{
+		{"not retried", nil, time.Time{}},
+		{"retried", []kgo.RecordHeader{{Key: RetryDueHeader, Value: []byte(strconv.FormatInt(at.UnixMilli(), 10))}}, at},
+		{"malformed", []kgo.RecordHeader{{Key: RetryDueHeader, Value: []byte("soon")}}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := due(&kgo.Record{Headers: tt.headers}); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI colours for levels, matching zap's coloured console output.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorFaint   = "\x1b[2m"
+)
+
+// ConsoleHandler is a slog.Handler that writes one human-readable line per
+// record: the time, the level, the message and then the attributes as
+// key=value pairs, optionally with colour.
+type ConsoleHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	opts  slog.HandlerOptions
+	color bool
+	attrs []byte
+	group string
+}
+
+var _ slog.Handler = (*ConsoleHandler)(nil)
+
+func NewConsoleHandler(w io.Writer, opts *slog.HandlerOptions, color bool) *ConsoleHandler {
+	h := &ConsoleHandler{w: w, mu: &sync.Mutex{}, color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := make([]byte, 0, 256)
+
+	t := record.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf = t.AppendFormat(buf, "2006-01-02T15:04:05.000Z0700")
+	buf = append(buf, ' ')
+	buf = h.appendLevel(buf, record.Level)
+	buf = append(buf, ' ')
+	buf = append(buf, record.Message...)
+	buf = append(buf, h.attrs...)
+
+	record.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, h.group, a)
+		return true
+	})
+
+	if h.opts.AddSource && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		buf = h.appendAttr(buf, "", slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", frame.File, frame.Line)))
+	}
+
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = h.appendAttr(h2.attrs, h.group, a)
+	}
+	return &h2
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+func (h *ConsoleHandler) appendLevel(buf []byte, level slog.Level) []byte {
+	label := fmt.Sprintf("%-5s", level.String())
+	if !h.color {
+		return append(buf, label...)
+	}
+
+	color := colorMagenta
+	switch {
+	case level >= slog.LevelError:
+		color = colorRed
+	case level >= slog.LevelWarn:
+		color = colorYellow
+	case level >= slog.LevelInfo:
+		color = colorBlue
+	}
+
+	return append(append(append(buf, color...), label...), colorReset...)
+}
+
+func (h *ConsoleHandler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(nil, a)
+	}
+
+	value := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	if value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range value.Group() {
+			buf = h.appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	if h.color {
+		buf = append(buf, colorFaint...)
+	}
+	buf = append(buf, prefix...)
+	buf = append(buf, a.Key...)
+	buf = append(buf, '=')
+	if h.color {
+		buf = append(buf, colorReset...)
+	}
+
+	return appendValue(buf, value)
+}
+
+func appendValue(buf []byte, value slog.Value) []byte {
+	var s string
+	switch value.Kind() {
+	case slog.KindTime:
+		s = value.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		s = value.Duration().String()
+	default:
+		s = value.String()
+	}
+
+	if needsQuoting(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needsQuoting(s string) bool {
+	return s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	})
+}
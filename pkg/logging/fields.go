@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// field is a flattened attribute: nested groups are joined with dots.
+type field struct {
+	key   string
+	value string
+}
+
+// fieldSet accumulates the attributes and groups of a handler, for the
+// sinks that send fields individually rather than as formatted text.
+type fieldSet struct {
+	fields []field
+	prefix string
+}
+
+func (s fieldSet) withAttrs(attrs []slog.Attr) fieldSet {
+	fields := append([]field(nil), s.fields...)
+	for _, a := range attrs {
+		fields = appendField(fields, s.prefix, a)
+	}
+	return fieldSet{fields: fields, prefix: s.prefix}
+}
+
+func (s fieldSet) withGroup(name string) fieldSet {
+	if name == "" {
+		return s
+	}
+	return fieldSet{fields: s.fields, prefix: s.prefix + name + "."}
+}
+
+// collect returns the handler's fields followed by those of record.
+func (s fieldSet) collect(record slog.Record) []field {
+	fields := append(make([]field, 0, len(s.fields)+record.NumAttrs()), s.fields...)
+	record.Attrs(func(a slog.Attr) bool {
+		fields = appendField(fields, s.prefix, a)
+		return true
+	})
+	return fields
+}
+
+func appendField(fields []field, prefix string, a slog.Attr) []field {
+	value := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range value.Group() {
+			fields = appendField(fields, prefix, ga)
+		}
+		return fields
+	}
+
+	var s string
+	switch value.Kind() {
+	case slog.KindTime:
+		s = value.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		s = value.Duration().String()
+	default:
+		s = value.String()
+	}
+
+	return append(fields, field{key: prefix + a.Key, value: s})
+}
+
+// source returns the file, line and function of the record's caller.
+func source(record slog.Record) (string, int, string, bool) {
+	if record.PC == 0 {
+		return "", 0, "", false
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+	return frame.File, frame.Line, frame.Function, frame.File != ""
+}
+
+// severity maps a level onto a syslog severity.
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+func sourceString(file string, line int) string {
+	return fmt.Sprintf("%s:%d", file, line)
+}
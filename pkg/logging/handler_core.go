@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"go.uber.org/zap/zapcore"
+)
+
+// handlerCore is a zapcore.Core that hands entries to a slog.Handler, so
+// that the zap backend can use the sinks that take structured entries.
+type handlerCore struct {
+	handler slog.Handler
+}
+
+var _ zapcore.Core = (*handlerCore)(nil)
+
+func newHandlerCore(handler slog.Handler) zapcore.Core {
+	return &handlerCore{handler: handler}
+}
+
+func (c *handlerCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+func (c *handlerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &handlerCore{handler: c.handler.WithAttrs(zapAttrs(fields))}
+}
+
+func (c *handlerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *handlerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var pc uintptr
+	if ent.Caller.Defined {
+		pc = ent.Caller.PC
+		// Callers frames are return addresses; make the PC look like one.
+		if pc != 0 {
+			pc++
+		}
+	}
+
+	record := slog.NewRecord(ent.Time, slogLevel(ent.Level), ent.Message, pc)
+	if ent.LoggerName != "" {
+		record.AddAttrs(slog.String(daemon.LoggerNameKey, ent.LoggerName))
+	}
+	record.AddAttrs(zapAttrs(fields)...)
+
+	return c.handler.Handle(context.Background(), record)
+}
+
+func (c *handlerCore) Sync() error {
+	return nil
+}
+
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level >= zapcore.ErrorLevel:
+		return slog.LevelError
+	case level >= zapcore.WarnLevel:
+		return slog.LevelWarn
+	case level >= zapcore.InfoLevel:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// zapAttrs converts fields to attributes, by way of zap's map encoder.
+func zapAttrs(fields []zapcore.Field) []slog.Attr {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	attrs := make([]slog.Attr, 0, len(enc.Fields))
+	for _, f := range fields {
+		if v, ok := enc.Fields[f.Key]; ok {
+			attrs = append(attrs, toAttr(f.Key, v))
+			delete(enc.Fields, f.Key)
+		}
+	}
+	return attrs
+}
+
+// toAttr turns the nested maps zap's map encoder produces into groups.
+func toAttr(key string, v any) slog.Attr {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return slog.Any(key, v)
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, toAttr(k, m[k]))
+	}
+	return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournalSocket is where journald listens for the native protocol.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// JournalHandler is a slog.Handler that speaks journald's native protocol.
+// Attributes are sent as journal fields, with their names upper-cased and
+// anything other than letters, digits and underscores replaced.
+//
+// Entries are sent as single datagrams, so an entry larger than the socket
+// allows is rejected rather than passed through a memfd.
+type JournalHandler struct {
+	conn       *net.UnixConn
+	mu         *sync.Mutex
+	identifier string
+	opts       slog.HandlerOptions
+	fields     fieldSet
+}
+
+var _ slog.Handler = (*JournalHandler)(nil)
+
+// NewJournalHandler connects to journald's socket at path, or at
+// DefaultJournalSocket if path is empty.
+func NewJournalHandler(path string, opts *slog.HandlerOptions) (*JournalHandler, error) {
+	if path == "" {
+		path = DefaultJournalSocket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to journald at %s: %w", path, err)
+	}
+
+	h := &JournalHandler{
+		conn:       conn,
+		mu:         &sync.Mutex{},
+		identifier: filepath.Base(os.Args[0]),
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h, nil
+}
+
+func (h *JournalHandler) Close() error {
+	return h.conn.Close()
+}
+
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *JournalHandler) Handle(_ context.Context, record slog.Record) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", record.Message)
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(severity(record.Level)))
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+
+	if h.opts.AddSource {
+		if file, line, function, ok := source(record); ok {
+			appendJournalField(&buf, "CODE_FILE", file)
+			appendJournalField(&buf, "CODE_LINE", strconv.Itoa(line))
+			appendJournalField(&buf, "CODE_FUNC", function)
+		}
+	}
+
+	for _, f := range h.fields.collect(record) {
+		appendJournalField(&buf, journalName(f.key), f.value)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.conn.Write(buf.Bytes())
+	return err
+}
+
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.fields = h.fields.withAttrs(attrs)
+	return &h2
+}
+
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.fields = h.fields.withGroup(name)
+	return &h2
+}
+
+// appendJournalField writes a field in the native protocol: KEY=value for
+// single-line values, and the binary length-prefixed form otherwise.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalName makes key a valid journal field name: upper-case letters,
+// digits and underscores, not starting with an underscore or a digit, and
+// at most 64 characters.
+func journalName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		name = "FIELD"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,40 @@
+// Package kafkalog passes the Kafka client's own logs on to a
+// daemon.Logger.
+package kafkalog
+
+import (
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// KgoLogger is a kgo.Logger that writes the Kafka client's own logs through
+// a daemon.Logger.
+type KgoLogger struct {
+	logger daemon.Logger
+	level  kgo.LogLevel
+}
+
+var _ kgo.Logger = (*KgoLogger)(nil)
+
+// NewKgoLogger returns a kgo.Logger for logger that passes on entries at or
+// above level.
+func NewKgoLogger(logger daemon.Logger, level kgo.LogLevel) *KgoLogger {
+	return &KgoLogger{logger: logger.Named("kgo"), level: level}
+}
+
+func (k *KgoLogger) Level() kgo.LogLevel {
+	return k.level
+}
+
+func (k *KgoLogger) Log(level kgo.LogLevel, msg string, keyvals ...any) {
+	switch level {
+	case kgo.LogLevelError:
+		k.logger.Error(msg, keyvals...)
+	case kgo.LogLevelWarn:
+		k.logger.Warn(msg, keyvals...)
+	case kgo.LogLevelInfo:
+		k.logger.Info(msg, keyvals...)
+	case kgo.LogLevelDebug:
+		k.logger.Debug(msg, keyvals...)
+	}
+}
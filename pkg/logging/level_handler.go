@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// sinkLevels are the levels a sink can give separate writers, lowest first.
+var sinkLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// levelBand returns the highest of sinkLevels that level is at or above.
+func levelBand(level slog.Level) slog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return slog.LevelError
+	case level >= slog.LevelWarn:
+		return slog.LevelWarn
+	case level >= slog.LevelInfo:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// levelHandler is a slog.Handler that hands each record to the handler
+// for its level, so that entries at each level can be written to a
+// different writer.
+type levelHandler struct {
+	handlers map[slog.Level]slog.Handler
+}
+
+var _ slog.Handler = (*levelHandler)(nil)
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handlers[levelBand(level)].Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handlers[levelBand(record.Level)].Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.each(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return h.each(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *levelHandler) each(f func(slog.Handler) slog.Handler) *levelHandler {
+	handlers := make(map[slog.Level]slog.Handler, len(h.handlers))
+	for level, next := range h.handlers {
+		handlers[level] = f(next)
+	}
+	return &levelHandler{handlers: handlers}
+}
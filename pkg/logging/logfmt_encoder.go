@@ -0,0 +1,228 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var bufferPool = buffer.NewPool()
+
+// logfmtEncoder is a zapcore.Encoder that writes entries as logfmt, the
+// same key=value format slog's TextHandler writes. Nested objects and
+// arrays are written as quoted JSON.
+type logfmtEncoder struct {
+	cfg       zapcore.EncoderConfig
+	buf       []byte
+	namespace string
+}
+
+// NewLogfmtEncoder returns a logfmt encoder that takes its keys and time
+// and duration encoding from cfg.
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: cfg}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	return &logfmtEncoder{cfg: e.cfg, buf: append([]byte(nil), e.buf...), namespace: e.namespace}
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := &logfmtEncoder{cfg: e.cfg}
+
+	if e.cfg.TimeKey != "" {
+		enc.AddString(e.cfg.TimeKey, ent.Time.Format(time.RFC3339Nano))
+	}
+	if e.cfg.LevelKey != "" {
+		enc.AddString(e.cfg.LevelKey, ent.Level.String())
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		enc.AddString(e.cfg.NameKey, ent.LoggerName)
+	}
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		enc.AddString(e.cfg.CallerKey, ent.Caller.TrimmedPath())
+	}
+	if e.cfg.MessageKey != "" {
+		enc.AddString(e.cfg.MessageKey, ent.Message)
+	}
+
+	// Fields added with With come after the entry's own keys.
+	enc.buf = append(enc.buf, e.buf...)
+	enc.namespace = e.namespace
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		enc.namespace = ""
+		enc.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	out := bufferPool.Get()
+	if len(enc.buf) > 0 {
+		out.Write(enc.buf[1:])
+	}
+	out.AppendByte('\n')
+	return out, nil
+}
+
+func (e *logfmtEncoder) appendKey(key string) {
+	e.buf = append(e.buf, ' ')
+	e.buf = append(e.buf, e.namespace...)
+	e.buf = append(e.buf, key...)
+	e.buf = append(e.buf, '=')
+}
+
+func (e *logfmtEncoder) addRaw(key, value string) {
+	e.appendKey(key)
+	if needsQuoting(value) {
+		e.buf = strconv.AppendQuote(e.buf, value)
+	} else {
+		e.buf = append(e.buf, value...)
+	}
+}
+
+func (e *logfmtEncoder) addJSON(key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.addRaw(key, string(b))
+	return nil
+}
+
+func (e *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := obj.MarshalLogObject(m); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields)
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.addRaw(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.addRaw(key, string(value))
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.addRaw(key, strconv.FormatBool(value))
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.addRaw(key, strconv.FormatComplex(value, 'g', -1, 128))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.addRaw(key, strconv.FormatComplex(complex128(value), 'g', -1, 64))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.addRaw(key, value.String())
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.addRaw(key, formatFloat(value, 64))
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.addRaw(key, formatFloat(float64(value), 32))
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.addRaw(key, strconv.FormatInt(value, 10))
+}
+
+func (e *logfmtEncoder) AddInt32(key string, value int32) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt16(key string, value int16) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddInt8(key string, value int8) {
+	e.AddInt64(key, int64(value))
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.addRaw(key, value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	e.addRaw(key, value.Format(time.RFC3339Nano))
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.addRaw(key, strconv.FormatUint(value, 10))
+}
+
+func (e *logfmtEncoder) AddUint32(key string, value uint32) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint16(key string, value uint16) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUint8(key string, value uint8) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) {
+	e.AddUint64(key, uint64(value))
+}
+
+func (e *logfmtEncoder) AddReflected(key string, value any) error {
+	switch v := value.(type) {
+	case fmt.Stringer:
+		e.addRaw(key, v.String())
+		return nil
+	case string:
+		e.addRaw(key, v)
+		return nil
+	default:
+		return e.addJSON(key, v)
+	}
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.namespace += key + "."
+}
+
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+}
@@ -0,0 +1,202 @@
+// Package logging builds daemon.Loggers from configuration: the backend,
+// the format entries are written in, where they are written to, and the
+// level below which they are dropped.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/adamstrickland/daemonic/pkg/daemon"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Backend string
+
+const (
+	BackendSlog Backend = "slog"
+	BackendZap  Backend = "zap"
+)
+
+type Format string
+
+const (
+	// FormatJSON writes one JSON object per entry.
+	FormatJSON Format = "json"
+	// FormatText writes human-readable lines without colour.
+	FormatText Format = "text"
+	// FormatLogfmt writes key=value pairs.
+	FormatLogfmt Format = "logfmt"
+	// FormatConsole writes human-readable lines with coloured levels.
+	FormatConsole Format = "console"
+)
+
+type Config struct {
+	Backend Backend
+	Format  Format
+	// Output names the destination; see openSink for the choices. Format
+	// is ignored for syslog+ and journald destinations, which take fields
+	// as they are.
+	Output string
+	// Rotation applies when Output is a file.
+	Rotation  Rotation
+	Level     slog.Level
+	AddSource bool
+	// Levels, if set, controls the level of each named logger, and
+	// Level is ignored. Otherwise a registry defaulting to Level is
+	// created.
+	Levels *daemon.LevelRegistry
+	// Redactor hides secrets from entries. If nil, daemon.DefaultRedactor
+	// is used.
+	Redactor *daemon.Redactor
+}
+
+// New builds a logger from cfg. The returned function flushes and closes
+// whatever the logger writes to.
+func New(cfg Config) (daemon.Logger, func(), error) {
+	out, err := openSink(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeOutput := out.close
+
+	levels := cfg.Levels
+	if levels == nil {
+		levels = daemon.NewLevelRegistry(cfg.Level)
+	}
+
+	redactor := daemon.WithRedactor(daemon.DefaultRedactor)
+	if cfg.Redactor != nil {
+		redactor = daemon.WithRedactor(cfg.Redactor)
+	}
+
+	// The backends log everything, and leave filtering to the registry so
+	// that levels can be changed at runtime.
+	switch cfg.Backend {
+	case BackendZap:
+		zlogger, err := newZap(cfg, out)
+		if err != nil {
+			closeOutput()
+			return nil, nil, err
+		}
+
+		cleanup := func() {
+			zlogger.Sync()
+			closeOutput()
+		}
+
+		return levels.Logger(daemon.NewZapAdapter(zlogger, redactor)), cleanup, nil
+	case BackendSlog, "":
+		handler, err := newSlogHandler(cfg, out)
+		if err != nil {
+			closeOutput()
+			return nil, nil, err
+		}
+
+		options := []daemon.AdapterOption{redactor}
+		if cfg.AddSource {
+			options = append(options, daemon.WithSource())
+		}
+
+		return levels.Logger(daemon.NewSlogAdapter(slog.New(handler), options...)), closeOutput, nil
+	default:
+		closeOutput()
+		return nil, nil, fmt.Errorf("unknown logging backend %q", cfg.Backend)
+	}
+}
+
+func newSlogHandler(cfg Config, out sink) (slog.Handler, error) {
+	if out.handler != nil {
+		return out.handler, nil
+	}
+
+	if out.writers != nil {
+		handlers := make(map[slog.Level]slog.Handler, len(sinkLevels))
+		for _, level := range sinkLevels {
+			h, err := newFormatHandler(cfg, out.writers[level])
+			if err != nil {
+				return nil, err
+			}
+			handlers[level] = h
+		}
+		return &levelHandler{handlers: handlers}, nil
+	}
+
+	return newFormatHandler(cfg, out.writer)
+}
+
+// newFormatHandler returns a handler that writes entries to w in the
+// configured format.
+func newFormatHandler(cfg Config, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: cfg.AddSource}
+
+	switch cfg.Format {
+	case FormatJSON, "":
+		return slog.NewJSONHandler(w, opts), nil
+	case FormatLogfmt:
+		return slog.NewTextHandler(w, opts), nil
+	case FormatText:
+		return NewConsoleHandler(w, opts, false), nil
+	case FormatConsole:
+		return NewConsoleHandler(w, opts, true), nil
+	default:
+		return nil, fmt.Errorf("unknown logging format %q", cfg.Format)
+	}
+}
+
+func newZap(cfg Config, out sink) (*zap.Logger, error) {
+	var opts []zap.Option
+	if cfg.AddSource {
+		opts = append(opts, zap.AddCaller())
+	}
+
+	if out.handler != nil {
+		return zap.New(newHandlerCore(out.handler), opts...), nil
+	}
+
+	if out.writers != nil {
+		cores := make([]zapcore.Core, 0, len(sinkLevels))
+		for _, level := range sinkLevels {
+			encoder, err := newZapEncoder(cfg)
+			if err != nil {
+				return nil, err
+			}
+			enabled := zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+				return slogLevel(l) == level
+			})
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(out.writers[level]), enabled))
+		}
+		return zap.New(zapcore.NewTee(cores...), opts...), nil
+	}
+
+	encoder, err := newZapEncoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	core := zapcore.NewCore(encoder, zapcore.AddSync(out.writer), zap.DebugLevel)
+
+	return zap.New(core, opts...), nil
+}
+
+// newZapEncoder returns an encoder for the configured format.
+func newZapEncoder(cfg Config) (zapcore.Encoder, error) {
+	var encoder zapcore.Encoder
+	switch cfg.Format {
+	case FormatJSON, "":
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case FormatLogfmt:
+		encoder = NewLogfmtEncoder(zap.NewProductionEncoderConfig())
+	case FormatText, FormatConsole:
+		ec := zap.NewDevelopmentEncoderConfig()
+		if cfg.Format == FormatConsole {
+			ec.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		encoder = zapcore.NewConsoleEncoder(ec)
+	default:
+		return nil, fmt.Errorf("unknown logging format %q", cfg.Format)
+	}
+
+	return encoder, nil
+}
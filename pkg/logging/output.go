@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// sink is where a logger's entries go: either a writer, which entries are
+// formatted for according to the configured format, or a handler that
+// takes entries with their fields intact. A sink that needs to know the
+// level of each entry, as the local syslog daemon does, has a writer for
+// each of sinkLevels instead.
+type sink struct {
+	writer  io.Writer
+	writers map[slog.Level]io.Writer
+	handler slog.Handler
+	close   func()
+}
+
+// openSink opens the destination named by cfg.Output, which is one of:
+//
+//	stdout, stderr            the standard streams
+//	syslog                    the local syslog daemon
+//	syslog+udp://host:port    an RFC 5424 receiver over UDP
+//	syslog+tcp://host:port    an RFC 5424 receiver over TCP
+//	syslog+unix:///path       an RFC 5424 receiver on a unix stream socket
+//	syslog+unixgram:///path   an RFC 5424 receiver on a unix datagram socket
+//	journald[:///path]        journald's native protocol
+//	anything else             the path of a file, rotated by cfg.Rotation
+//	                          and reopened on SIGHUP
+func openSink(cfg Config) (sink, error) {
+	output := cfg.Output
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: cfg.AddSource}
+
+	switch {
+	case output == "stdout" || output == "":
+		return sink{writer: os.Stdout, close: func() {}}, nil
+	case output == "stderr":
+		return sink{writer: os.Stderr, close: func() {}}, nil
+	case output == "syslog":
+		writers, closer, err := openSyslog()
+		if err != nil {
+			return sink{}, err
+		}
+		return sink{writers: writers, close: closer}, nil
+	case strings.HasPrefix(output, "syslog+"):
+		u, err := url.Parse(strings.TrimPrefix(output, "syslog+"))
+		if err != nil {
+			return sink{}, fmt.Errorf("invalid syslog address %q: %w", output, err)
+		}
+
+		addr := u.Host
+		if u.Scheme == "unix" || u.Scheme == "unixgram" {
+			addr = u.Path
+		}
+
+		h, err := NewSyslogHandler(u.Scheme, addr, opts)
+		if err != nil {
+			return sink{}, err
+		}
+		return sink{handler: h, close: func() { h.Close() }}, nil
+	case output == "journald" || strings.HasPrefix(output, "journald:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(output, "journald"), "://")
+		h, err := NewJournalHandler(path, opts)
+		if err != nil {
+			return sink{}, err
+		}
+		return sink{handler: h, close: func() { h.Close() }}, nil
+	default:
+		f, err := OpenRotatingFile(output, cfg.Rotation)
+		if err != nil {
+			return sink{}, err
+		}
+
+		stop := f.ReopenOnSignal()
+		cleanup := func() {
+			stop()
+			f.Close()
+		}
+
+		return sink{writer: f, close: cleanup}, nil
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"os"
+	"path/filepath"
+)
+
+// openSyslog connects to the local syslog daemon, with a writer for each of
+// sinkLevels that logs at the matching severity.
+func openSyslog() (map[slog.Level]io.Writer, func(), error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, filepath.Base(os.Args[0]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to syslog: %w", err)
+	}
+
+	writers := map[slog.Level]io.Writer{
+		slog.LevelDebug: syslogWriter(w.Debug),
+		slog.LevelInfo:  syslogWriter(w.Info),
+		slog.LevelWarn:  syslogWriter(w.Warning),
+		slog.LevelError: syslogWriter(w.Err),
+	}
+
+	return writers, func() { w.Close() }, nil
+}
+
+// syslogWriter writes to syslog at the severity of the method it is.
+type syslogWriter func(string) error
+
+func (f syslogWriter) Write(p []byte) (int, error) {
+	if err := f(string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+func openSyslog() (map[slog.Level]io.Writer, func(), error) {
+	return nil, nil, fmt.Errorf("syslog is not supported on this platform")
+}
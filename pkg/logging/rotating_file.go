@@ -0,0 +1,318 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is appended to the name of a rotated file. It sorts in
+// the order the files were rotated in; files rotated within the same
+// millisecond are told apart by a counter after it.
+const backupTimeFormat = "20060102T150405.000"
+
+// Rotation controls when a RotatingFile starts a new file, and how many of
+// the old ones it keeps. Zero values disable the corresponding limit.
+type Rotation struct {
+	// MaxSize is the size in bytes a file may grow to before it is
+	// rotated.
+	MaxSize int64
+	// MaxAge is how long a file is written to before it is rotated.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to keep.
+	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// RotatingFile is an io.Writer that appends to a file, rotating it
+// according to a Rotation. It can also be told to reopen the file, so that
+// it works alongside an external logrotate.
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// cleanup serialises compression and removal of old files, and
+	// pruning lets Close wait for it.
+	cleanup sync.Mutex
+	pruning sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*RotatingFile)(nil)
+
+func OpenRotatingFile(path string, rotation Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: rotation}
+	if err := f.open(f.path); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Keep logging to the file that could not be rotated.
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file, and waits for rotated files to be compressed and
+// pruned.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.pruning.Wait()
+	return err
+}
+
+// Reopen opens path again, picking up a new file if the old one has been
+// moved away. The old file is kept if the new one cannot be opened.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.file
+	if err := f.open(f.path); err != nil {
+		return err
+	}
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Rotate starts a new file now, regardless of the rotation limits.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+// ReopenOnSignal reopens the file whenever one of sigs arrives (SIGHUP if
+// none are given), until the returned function is called.
+func (f *RotatingFile) ReopenOnSignal(sigs ...os.Signal) func() {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sigs...)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigCh:
+				if err := f.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "error reopening log file: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigCh)
+			close(done)
+		})
+	}
+}
+
+// open opens path to write to. It must be called with f.mu held.
+func (f *RotatingFile) open(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("error creating log directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// due must be called with f.mu held.
+func (f *RotatingFile) due(n int64) bool {
+	if f.rotation.MaxSize > 0 && f.size > 0 && f.size+n > f.rotation.MaxSize {
+		return true
+	}
+	if f.rotation.MaxAge > 0 && time.Since(f.opened) >= f.rotation.MaxAge {
+		return true
+	}
+	return false
+}
+
+// rotate must be called with f.mu held. If the file cannot be rotated, the
+// old one is opened again, so that there is still one to write to.
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		err := f.file.Close()
+		f.file = nil
+		if err != nil {
+			return errors.Join(fmt.Errorf("error closing log file: %w", err), f.open(f.path))
+		}
+	}
+
+	backup := backupName(f.path, time.Now())
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Join(fmt.Errorf("error rotating log file: %w", err), f.open(f.path))
+	}
+
+	if err := f.open(f.path); err != nil {
+		// The backup is still being written to, so it is not pruned.
+		return errors.Join(err, f.open(backup))
+	}
+
+	f.pruning.Add(1)
+	go func() {
+		defer f.pruning.Done()
+		f.prune(backup)
+	}()
+	return nil
+}
+
+// backupName returns the name to rotate path to at t, adding a counter if
+// a file has already been rotated to that name.
+func backupName(path string, t time.Time) string {
+	stamp := path + "." + t.Format(backupTimeFormat)
+
+	backup := stamp
+	for i := 1; exists(backup) || exists(backup+".gz"); i++ {
+		backup = fmt.Sprintf("%s.%03d", stamp, i)
+	}
+	return backup
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// prune compresses the newly rotated backup if asked to, and removes the
+// oldest backups beyond the retention count.
+func (f *RotatingFile) prune(backup string) {
+	f.cleanup.Lock()
+	defer f.cleanup.Unlock()
+
+	if f.rotation.Compress {
+		// The backup may already have been pruned, if the files were
+		// rotated faster than they could be compressed.
+		if err := compress(backup); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "error compressing log file: %v\n", err)
+		}
+	}
+
+	if f.rotation.MaxBackups <= 0 {
+		return
+	}
+
+	// Only match our own backups, and leave anything else (such as the
+	// files an external logrotate produces) alone.
+	backups, err := filepath.Glob(f.path + ".????????T??????.???*")
+	if err != nil {
+		return
+	}
+
+	// Compare by timestamp and counter, ignoring any .gz suffix.
+	slices.SortFunc(backups, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+
+	for len(backups) > f.rotation.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
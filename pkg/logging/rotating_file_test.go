@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	f, err := OpenRotatingFile(path, Rotation{MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate more often than the backup names' resolution, to check that
+	// each backup is kept apart.
+	for range 4 {
+		if _, err := f.Write([]byte("entry\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("got backups %v, want 2", backups)
+	}
+}
+
+func TestRotatingFileFailedRotation(t *testing.T) {
+	// Leave no room in the name for the backup suffix, so that the file
+	// cannot be renamed.
+	path := filepath.Join(t.TempDir(), strings.Repeat("x", 250))
+	f, err := OpenRotatingFile(path, Rotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("before\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Rotate(); err == nil {
+		t.Fatal("rotation succeeded")
+	}
+	if _, err := f.Write([]byte("after\n")); err != nil {
+		t.Fatalf("writing after a failed rotation: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "before\nafter\n" {
+		t.Errorf("got %q, want both entries", got)
+	}
+}
@@ -0,0 +1,229 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// facilityDaemon is the syslog facility for system daemons.
+	facilityDaemon = 3
+	// DefaultSDID is the structured data ID fields are sent under. 32473
+	// is the private enterprise number IANA reserves for examples.
+	DefaultSDID = "fields@32473"
+)
+
+// SyslogHandler is a slog.Handler that sends RFC 5424 syslog messages over
+// UDP, TCP or a unix socket. Attributes are sent as structured data rather
+// than being flattened into the message. Messages sent over a stream, TCP
+// or a unix stream socket, are framed by octet counting, as RFC 6587
+// describes.
+type SyslogHandler struct {
+	conn   *syslogConn
+	opts   slog.HandlerOptions
+	fields fieldSet
+}
+
+var _ slog.Handler = (*SyslogHandler)(nil)
+
+type syslogConn struct {
+	network  string
+	addr     string
+	hostname string
+	appName  string
+	procID   string
+	sdID     string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogHandler connects to the syslog receiver at addr over network,
+// which is one of "udp", "tcp", "unix" or "unixgram".
+func NewSyslogHandler(network, addr string, opts *slog.HandlerOptions) (*SyslogHandler, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	c := &syslogConn{
+		network:  network,
+		addr:     addr,
+		hostname: hostname,
+		appName:  printable(filepath.Base(os.Args[0]), 48),
+		procID:   strconv.Itoa(os.Getpid()),
+		sdID:     DefaultSDID,
+	}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+
+	h := &SyslogHandler{conn: c}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h, nil
+}
+
+func (h *SyslogHandler) Close() error {
+	h.conn.mu.Lock()
+	defer h.conn.mu.Unlock()
+
+	if h.conn.conn == nil {
+		return nil
+	}
+	err := h.conn.conn.Close()
+	h.conn.conn = nil
+	return err
+}
+
+func (h *SyslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *SyslogHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := h.fields.collect(record)
+	if h.opts.AddSource {
+		if file, line, _, ok := source(record); ok {
+			fields = append(fields, field{key: slog.SourceKey, value: sourceString(file, line)})
+		}
+	}
+
+	return h.conn.send(h.conn.format(record, fields))
+}
+
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SyslogHandler{conn: h.conn, opts: h.opts, fields: h.fields.withAttrs(attrs)}
+}
+
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	return &SyslogHandler{conn: h.conn, opts: h.opts, fields: h.fields.withGroup(name)}
+}
+
+// format renders an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID name="value"...] MSG
+func (c *syslogConn) format(record slog.Record, fields []field) []byte {
+	t := record.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - ",
+		facilityDaemon*8+severity(record.Level),
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		c.hostname, c.appName, c.procID)
+
+	if len(fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + c.sdID)
+		for _, f := range fields {
+			b.WriteString(" " + sdName(f.key) + `="` + sdEscape(f.value) + `"`)
+		}
+		b.WriteString("]")
+	}
+
+	b.WriteString(" " + record.Message)
+	return []byte(b.String())
+}
+
+func (c *syslogConn) dial() error {
+	conn, err := net.Dial(c.network, c.addr)
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog at %s %s: %w", c.network, c.addr, err)
+	}
+
+	c.conn = conn
+	return nil
+}
+
+// stream reports whether messages run together on the connection, and so
+// have to be framed.
+func (c *syslogConn) stream() bool {
+	switch c.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	default:
+		return false
+	}
+}
+
+// send writes msg, reconnecting once if the connection has gone away.
+func (c *syslogConn) send(msg []byte) error {
+	if c.stream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		if _, err := c.conn.Write(msg); err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	if err := c.dial(); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// sdName makes key a valid SD-NAME: at most 32 printable ASCII characters,
+// excluding '=', ' ', ']' and '"'.
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// sdEscape escapes the characters RFC 5424 requires to be escaped in a
+// PARAM-VALUE.
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func printable(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syslogLine matches the message the tests log, after the header.
+var syslogLine = regexp.MustCompile(`^<30>1 \S+ \S+ \S+ \d+ - \[fields@32473 n="(\d)"\] hello$`)
+
+func TestSyslogHandlerUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	logTwice(t, "udp", pc.LocalAddr().String())
+
+	buf := make([]byte, 2048)
+	for i := range 2 {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMessage(t, string(buf[:n]), i)
+	}
+}
+
+func TestSyslogHandlerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	testStream(t, ln, "tcp", ln.Addr().String())
+}
+
+func TestSyslogHandlerUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not reliably available on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	testStream(t, ln, "unix", path)
+}
+
+// testStream checks that messages sent over a stream are framed by octet
+// counting, so that they can be told apart.
+func testStream(t *testing.T, ln net.Listener, network, addr string) {
+	t.Helper()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	logTwice(t, network, addr)
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection accepted")
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	for i := range 2 {
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Fatalf("message %d not framed: %q", i, prefix)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		assertMessage(t, string(msg), i)
+	}
+}
+
+func logTwice(t *testing.T, network, addr string) {
+	t.Helper()
+
+	h, err := NewSyslogHandler(network, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	logger := slog.New(h)
+	logger.Info("hello", "n", 0)
+	logger.Info("hello", "n", 1)
+}
+
+func assertMessage(t *testing.T, msg string, n int) {
+	t.Helper()
+
+	m := syslogLine.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("unexpected message: %q", msg)
+	}
+	if m[1] != strconv.Itoa(n) {
+		t.Errorf("got message %s, want %d", m[1], n)
+	}
+}